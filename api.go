@@ -0,0 +1,379 @@
+package main
+
+import (
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+	"gopkg.in/ini.v1"
+)
+
+const API_PREFIX = "/api/v1"
+const API_TOKEN_ENV = "WG_VLAN_API_TOKEN"
+const API_QR_SIZE = 512
+
+// API_MAX_BODY_BYTES caps request bodies, which are small JSON objects
+const API_MAX_BODY_BYTES = 64 << 10
+
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// APIServer serves the REST API over a VLAN config file. The file is read afresh for every request, so changes made
+// by other commands are picked up; requests that change the VLAN hold the write lock and the config file's lock from
+// reading the file until it has been written back.
+type APIServer struct {
+	ConfigFile string
+	// Token is the bearer token that every request but the one for the OpenAPI description must carry
+	Token  string
+	Logger *log.Logger
+	// Recorder records every change, as the global --git and --audit-log flags ask
+	Recorder ChangeRecorder
+
+	mu sync.RWMutex
+}
+
+// apiClientRequest is the body of a request adding a client. Without a public key, a key pair is generated.
+type apiClientRequest struct {
+	PeerName    string   `json:"peer_name"`
+	PublicKey   string   `json:"public_key"`
+	Owner       string   `json:"owner"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	// ExpiresAt takes the same forms as client-add's --expires flag
+	ExpiresAt string `json:"expires_at"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func (api *APIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, API_PREFIX+"/") {
+		api.writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	path := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, API_PREFIX), "/"), "/")
+
+	if len(path) == 1 && path[0] == "openapi.json" {
+		if api.allowMethods(w, r, http.MethodGet) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(openAPIDocument)
+		}
+		return
+	}
+
+	if !api.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="wg-vlan"`)
+		api.writeError(w, http.StatusUnauthorized, errors.New("missing or invalid bearer token"))
+		return
+	}
+
+	switch {
+	case len(path) == 2 && path[0] == "server" && path[1] == "ini":
+		if api.allowMethods(w, r, http.MethodGet) {
+			api.getServerIni(w)
+		}
+	case len(path) == 1 && path[0] == "clients":
+		if api.allowMethods(w, r, http.MethodGet, http.MethodPost) {
+			if r.Method == http.MethodGet {
+				api.listClients(w, r)
+			} else {
+				api.addClient(w, r)
+			}
+		}
+	case len(path) == 2 && path[0] == "clients":
+		if api.allowMethods(w, r, http.MethodGet, http.MethodDelete) {
+			if r.Method == http.MethodGet {
+				api.getClient(w, path[1])
+			} else {
+				api.removeClient(w, path[1])
+			}
+		}
+	case len(path) == 3 && path[0] == "clients" && (path[2] == "ini" || path[2] == "qr"):
+		if api.allowMethods(w, r, http.MethodGet) {
+			api.getClientIni(w, path[1], path[2] == "qr")
+		}
+	default:
+		api.writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+func (api *APIServer) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || api.Token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(api.Token)) == 1
+}
+
+// allowMethods answers requests with other methods than the given ones, returning whether the request may proceed
+func (api *APIServer) allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	api.writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	return false
+}
+
+// lockForUpdate takes the write lock and the config file's lock, for a request that changes the VLAN, and returns the
+// function releasing them. If the file cannot be locked, it writes the error and returns nil.
+func (api *APIServer) lockForUpdate(w http.ResponseWriter) func() {
+	api.mu.Lock()
+	configLock, err := lockConfigFile(api.ConfigFile)
+	if err != nil {
+		api.mu.Unlock()
+		api.writeError(w, http.StatusInternalServerError, err)
+		return nil
+	}
+	return func() {
+		configLock.Close()
+		api.mu.Unlock()
+	}
+}
+
+// load reads the VLAN config; callers must hold the lock
+func (api *APIServer) load(w http.ResponseWriter) *VLAN {
+	vlan, err := VLANFromFile(api.ConfigFile, api.Logger)
+	if err != nil {
+		api.writeError(w, http.StatusInternalServerError, err)
+		return nil
+	}
+	return vlan
+}
+
+// save validates and writes the VLAN config; callers must hold the write lock
+func (api *APIServer) save(w http.ResponseWriter, vlan *VLAN, change VLANChange) bool {
+	if _, err := vlan.Validate(); err != nil {
+		api.writeError(w, http.StatusConflict, err)
+		return false
+	}
+	written, _, err := VLANSaver{Recorder: api.Recorder, Logger: api.Logger}.Save(vlan, api.ConfigFile, change)
+	if !written {
+		api.writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to write config file: %w", err))
+		return false
+	}
+	if err != nil && api.Logger != nil {
+		// The change has been made, so the request still succeeds
+		api.Logger.Printf("error: %s", err.Error())
+	}
+	return true
+}
+
+func (api *APIServer) getServerIni(w http.ResponseWriter) {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	vlan := api.load(w)
+	if vlan == nil {
+		return
+	}
+	iniFile, err := vlan.ServerIni()
+	if err != nil {
+		api.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	api.writeIni(w, iniFile, false)
+}
+
+func (api *APIServer) listClients(w http.ResponseWriter, r *http.Request) {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	vlan := api.load(w)
+	if vlan == nil {
+		return
+	}
+
+	query := r.URL.Query()
+	filtered := vlan.FilterClients(ClientFilter{Tags: query["tag"], Owners: query["owner"]})
+	clients := []*VLANClient{}
+	for _, client := range filtered.Clients {
+		client, err := client.exported(true)
+		if err != nil {
+			api.writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		clients = append(clients, client)
+	}
+	api.writeJSON(w, http.StatusOK, clients)
+}
+
+func (api *APIServer) getClient(w http.ResponseWriter, name string) {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	vlan := api.load(w)
+	if vlan == nil {
+		return
+	}
+	client := vlan.Client(name)
+	if client == nil {
+		api.writeError(w, http.StatusNotFound, fmt.Errorf("no such client: %s", name))
+		return
+	}
+	exported, err := client.exported(true)
+	if err != nil {
+		api.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	api.writeJSON(w, http.StatusOK, exported)
+}
+
+func (api *APIServer) addClient(w http.ResponseWriter, r *http.Request) {
+	request := apiClientRequest{}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, API_MAX_BODY_BYTES))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		api.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+
+	unlock := api.lockForUpdate(w)
+	if unlock == nil {
+		return
+	}
+	defer unlock()
+	vlan := api.load(w)
+	if vlan == nil {
+		return
+	}
+
+	var client *VLANClient
+	var err error
+	if request.PublicKey == "" {
+		client, err = vlan.NewClient(request.PeerName, "")
+	} else if _, err = WireguardPublicKey(request.PublicKey); err != nil {
+		err = fmt.Errorf("invalid public key '%s': %w", request.PublicKey, err)
+	} else {
+		client, err = vlan.NewClientPublic(request.PeerName, request.PublicKey)
+	}
+	if err != nil {
+		api.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	client.Owner = request.Owner
+	client.Description = request.Description
+	client.Tags = request.Tags
+	if request.ExpiresAt != "" {
+		expiry, err := ParseExpiry(request.ExpiresAt, time.Now())
+		if err != nil {
+			api.writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		client.ExpiresAt = &expiry
+	}
+	if _, err := client.Validate(); err != nil {
+		api.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if !api.save(w, vlan, VLANChange{Command: "client-add", Args: []string{client.PeerName, client.Network}, Peers: []ChangedPeer{changedClient(client)}}) {
+		return
+	}
+	api.logf("added client: %s - %s", client.PeerName, client.Network)
+
+	exported, err := client.exported(true)
+	if err != nil {
+		api.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Location", API_PREFIX+"/clients/"+url.PathEscape(client.PeerName))
+	api.writeJSON(w, http.StatusCreated, exported)
+}
+
+func (api *APIServer) removeClient(w http.ResponseWriter, name string) {
+	unlock := api.lockForUpdate(w)
+	if unlock == nil {
+		return
+	}
+	defer unlock()
+	vlan := api.load(w)
+	if vlan == nil {
+		return
+	}
+	client := vlan.Client(name)
+	if err := vlan.RemoveClient(name); err != nil {
+		api.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if !api.save(w, vlan, VLANChange{Command: "client-remove", Args: []string{name}, Peers: []ChangedPeer{changedClient(client)}}) {
+		return
+	}
+	api.logf("removed client: %s", name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *APIServer) getClientIni(w http.ResponseWriter, name string, qr bool) {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	vlan := api.load(w)
+	if vlan == nil {
+		return
+	}
+	if vlan.Client(name) == nil {
+		api.writeError(w, http.StatusNotFound, fmt.Errorf("no such client: %s", name))
+		return
+	}
+	iniFile, err := vlan.ClientIni(name)
+	if err != nil {
+		// Clients registered with only a public key have no config to hand out
+		api.writeError(w, http.StatusConflict, err)
+		return
+	}
+	api.writeIni(w, iniFile, qr)
+}
+
+// writeIni renders an INI config as text or, with qr, as a PNG QR code
+func (api *APIServer) writeIni(w http.ResponseWriter, iniFile *ini.File, qr bool) {
+	iniText, err := IniString(iniFile)
+	if err != nil {
+		api.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !qr {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(iniText))
+		return
+	}
+	png, err := qrcode.Encode(iniText, qrcode.Low, API_QR_SIZE)
+	if err != nil {
+		api.writeError(w, http.StatusInternalServerError, fmt.Errorf("error constructing QR: %w", err))
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(png)
+}
+
+func (api *APIServer) writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	out, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		api.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(out, '\n'))
+}
+
+func (api *APIServer) writeError(w http.ResponseWriter, status int, err error) {
+	out, _ := json.Marshal(apiError{Error: err.Error()})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(out, '\n'))
+}
+
+func (api *APIServer) logf(format string, args ...interface{}) {
+	if api.Logger != nil {
+		api.Logger.Printf(format, args...)
+	}
+}
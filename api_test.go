@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testAPIToken = "s3cret"
+
+func newTestAPI(t *testing.T, configName string) (*httptest.Server, string) {
+	t.Helper()
+	path := copyTestConfig(t, configName)
+	server := httptest.NewServer(&APIServer{ConfigFile: path, Token: testAPIToken})
+	t.Cleanup(server.Close)
+	return server, path
+}
+
+func apiRequest(t *testing.T, server *httptest.Server, method string, path string, body string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, server.URL+API_PREFIX+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testAPIToken)
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	buf := bytes.Buffer{}
+	buf.ReadFrom(resp.Body)
+	return resp, buf.Bytes()
+}
+
+func TestAPIAuth(t *testing.T) {
+	server, _ := newTestAPI(t, "basic.yaml")
+	for _, header := range []string{"", "Bearer wrong", testAPIToken} {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+API_PREFIX+"/clients", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		resp, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected 401 for Authorization '%s', got %d", header, resp.StatusCode)
+		}
+	}
+
+	// The API description is public
+	resp, err := server.Client().Get(server.URL + API_PREFIX + "/openapi.json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	spec := map[string]interface{}{}
+	if err := json.NewDecoder(resp.Body).Decode(&spec); err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("expected OpenAPI document, got %d: %v", resp.StatusCode, err)
+	}
+}
+
+func TestAPIReadClients(t *testing.T) {
+	server, _ := newTestAPI(t, "basic.yaml")
+
+	resp, body := apiRequest(t, server, http.MethodGet, "/clients", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.StatusCode, body)
+	}
+	clients := []VLANClient{}
+	if err := json.Unmarshal(body, &clients); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(clients) != 3 || clients[0].PeerName != "alice" || clients[0].PublicKey == "" {
+		t.Errorf("unexpected clients: %+v", clients)
+	}
+	if bytes.Contains(body, []byte("private_key")) || bytes.Contains(body, []byte("preshared_key")) {
+		t.Errorf("expected keys to be redacted, got %s", body)
+	}
+
+	resp, body = apiRequest(t, server, http.MethodGet, "/clients/alice/ini", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.StatusCode, body)
+	}
+	assertGolden(t, "basic_alice.ini", string(body))
+
+	resp, body = apiRequest(t, server, http.MethodGet, "/server/ini", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.StatusCode, body)
+	}
+	assertGolden(t, "basic_server.ini", string(body))
+
+	resp, body = apiRequest(t, server, http.MethodGet, "/clients/alice/qr", "")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "image/png" || !bytes.HasPrefix(body, []byte("\x89PNG")) {
+		t.Errorf("expected PNG, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		status int
+	}{
+		{http.MethodGet, "/clients/nobody", http.StatusNotFound},
+		{http.MethodGet, "/clients/nobody/ini", http.StatusNotFound},
+		{http.MethodGet, "/clients/phone/ini", http.StatusConflict},
+		{http.MethodPut, "/clients/alice", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/nothing", http.StatusNotFound},
+	} {
+		resp, body := apiRequest(t, server, tc.method, tc.path, "")
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s %s: expected %d, got %d: %s", tc.method, tc.path, tc.status, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestAPIAddRemoveClient(t *testing.T) {
+	server, path := newTestAPI(t, "basic.yaml")
+
+	resp, body := apiRequest(t, server, http.MethodPost, "/clients", `{"peer_name": "carol", "tags": ["phones"], "expires_at": "30d"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", resp.StatusCode, body)
+	}
+	if resp.Header.Get("Location") != API_PREFIX+"/clients/carol" {
+		t.Errorf("unexpected location: %s", resp.Header.Get("Location"))
+	}
+	vlan, err := VLANFromFile(path, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	carol := vlan.Client("carol")
+	if carol == nil || carol.Network != "10.20.30.5" || carol.PrivateKey == "" || carol.ExpiresAt == nil {
+		t.Fatalf("expected carol to be written to the config, got %+v", carol)
+	}
+
+	for _, tc := range []struct {
+		name string
+		body string
+	}{
+		{"duplicate", `{"peer_name": "carol"}`},
+		{"bad key", `{"peer_name": "dave", "public_key": "nope"}`},
+		{"unknown field", `{"peer_name": "dave", "color": "red"}`},
+		{"bad expiry", `{"peer_name": "dave", "expires_at": "soon"}`},
+	} {
+		resp, body := apiRequest(t, server, http.MethodPost, "/clients", tc.body)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", tc.name, resp.StatusCode, body)
+		}
+	}
+
+	resp, body = apiRequest(t, server, http.MethodDelete, "/clients/carol", "")
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", resp.StatusCode, body)
+	}
+	resp, _ = apiRequest(t, server, http.MethodDelete, "/clients/carol", "")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 removing a removed client, got %d", resp.StatusCode)
+	}
+}
+
+func TestAPIRemoveReferencedClient(t *testing.T) {
+	server, _ := newTestAPI(t, "allow.yaml")
+	resp, body := apiRequest(t, server, http.MethodDelete, "/clients/bob", "")
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected 409 removing a client named by allow rules, got %d: %s", resp.StatusCode, body)
+	}
+}
+
+func TestAPIConcurrentAdds(t *testing.T) {
+	server, path := newTestAPI(t, "basic.yaml")
+	names := []string{"c1", "c2", "c3", "c4", "c5", "c6", "c7", "c8"}
+	wg := sync.WaitGroup{}
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			resp, body := apiRequest(t, server, http.MethodPost, "/clients", `{"peer_name": "`+name+`"}`)
+			if resp.StatusCode != http.StatusCreated {
+				t.Errorf("expected 201 for %s, got %d: %s", name, resp.StatusCode, body)
+			}
+		}(name)
+	}
+	wg.Wait()
+
+	vlan, err := VLANFromFile(path, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	addresses := map[string]bool{}
+	for _, client := range vlan.Clients {
+		addresses[client.Network] = true
+	}
+	if len(vlan.Clients) != 3+len(names) || len(addresses) != len(vlan.Clients) {
+		t.Errorf("expected every add to be kept at a distinct address, got %d clients at %d addresses", len(vlan.Clients), len(addresses))
+	}
+}
+
+func TestAPIConcurrentAddsAcrossServers(t *testing.T) {
+	// Two servers over one config stand for separate processes, which only the config file's lock keeps apart
+	first, path := newTestAPI(t, "basic.yaml")
+	second := httptest.NewServer(&APIServer{ConfigFile: path, Token: testAPIToken})
+	t.Cleanup(second.Close)
+
+	names := []string{"c1", "c2", "c3", "c4", "c5", "c6", "c7", "c8"}
+	wg := sync.WaitGroup{}
+	for idx, name := range names {
+		server := first
+		if idx%2 == 1 {
+			server = second
+		}
+		wg.Add(1)
+		go func(server *httptest.Server, name string) {
+			defer wg.Done()
+			resp, body := apiRequest(t, server, http.MethodPost, "/clients", `{"peer_name": "`+name+`"}`)
+			if resp.StatusCode != http.StatusCreated {
+				t.Errorf("expected 201 for %s, got %d: %s", name, resp.StatusCode, body)
+			}
+		}(server, name)
+	}
+	wg.Wait()
+
+	vlan, err := VLANFromFile(path, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(vlan.Clients) != 3+len(names) {
+		t.Errorf("expected every add to be kept, got %d clients", len(vlan.Clients))
+	}
+}
+
+func TestAPIBodyLimit(t *testing.T) {
+	server, path := newTestAPI(t, "basic.yaml")
+	body := `{"peer_name": "carol", "public_key": "` + strings.Repeat("A", API_MAX_BODY_BYTES) + `"}`
+	resp, _ := apiRequest(t, server, http.MethodPost, "/clients", body)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for an oversized body, got %d", resp.StatusCode)
+	}
+	if vlan, err := VLANFromFile(path, nil); err != nil || vlan.Client("carol") != nil {
+		t.Errorf("expected no client to be added, got %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const AUDIT_LOG_FLAG = "audit-log"
+const AUDIT_LOG_ENV = "WG_VLAN_AUDIT_LOG"
+const AUDIT_ACTOR_FLAG = "actor"
+
+// AUDIT_GENESIS_HASH is the previous hash of the first entry in an audit log
+var AUDIT_GENESIS_HASH = strings.Repeat("0", sha256.Size*2)
+
+// AuditEntry is one line of the audit log, recording a change to one peer. Its hash covers its other fields, including
+// the hash of the entry before it, so that changing, inserting or removing entries breaks the chain from there on.
+type AuditEntry struct {
+	Time    string   `json:"time"`
+	Actor   string   `json:"actor"`
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+	Config  string   `json:"config"`
+	Peer    string   `json:"peer,omitempty"`
+	// Fingerprint identifies the peer's public key, as given by PublicKeyFingerprint
+	Fingerprint string `json:"fingerprint,omitempty"`
+	PrevHash    string `json:"prev_hash"`
+	Hash        string `json:"hash"`
+}
+
+// ComputeHash hashes the entry's JSON encoding without its hash
+func (entry AuditEntry) ComputeHash() (string, error) {
+	entry.Hash = ""
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// auditEntries records a change as one entry per affected peer, or a single entry if it affected none
+func (change VLANChange) auditEntries(actor string, config string, now time.Time) []AuditEntry {
+	base := AuditEntry{
+		Time:    now.UTC().Format(time.RFC3339Nano),
+		Actor:   actor,
+		Command: change.Command,
+		Args:    change.Args,
+		Config:  config,
+	}
+	if len(change.Peers) == 0 {
+		return []AuditEntry{base}
+	}
+	entries := []AuditEntry{}
+	for _, peer := range change.Peers {
+		entry := base
+		entry.Peer = peer.Name
+		entry.Fingerprint = PublicKeyFingerprint(peer.PublicKey)
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// auditActor names who is making changes: the given actor, or else the user running wg-vlan, which under sudo is the
+// user who ran sudo rather than root
+func auditActor(actor string) string {
+	if actor == "" {
+		actor = os.Getenv("SUDO_USER")
+	}
+	if actor == "" {
+		actor = os.Getenv("USER")
+	}
+	if actor == "" {
+		actor = "unknown"
+	}
+	return actor
+}
+
+// AppendAuditLog chains entries onto the end of the audit log at path, creating it if needed. The log is locked while
+// its last hash is read and the entries are appended, so that concurrent writers cannot chain onto the same entry.
+func AppendAuditLog(path string, entries []AuditEntry) error {
+	fp, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	// Closing the file releases the lock
+	defer fp.Close()
+	if err := lockFile(fp); err != nil {
+		return fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+
+	prevHash, err := lastAuditHash(fp, path)
+	if err != nil {
+		return err
+	}
+
+	buf := bytes.Buffer{}
+	for _, entry := range entries {
+		entry.PrevHash = prevHash
+		if entry.Hash, err = entry.ComputeHash(); err != nil {
+			return err
+		}
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(line, '\n'))
+		prevHash = entry.Hash
+	}
+
+	if _, err := fp.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return fp.Close()
+}
+
+// lastAuditHash reads the hash of the last entry in the audit log at path, for chaining the next one
+func lastAuditHash(r io.Reader, path string) (string, error) {
+	last := ""
+	scanner := newAuditScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			last = line
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if last == "" {
+		return AUDIT_GENESIS_HASH, nil
+	}
+
+	entry := AuditEntry{}
+	if err := json.Unmarshal([]byte(last), &entry); err != nil || entry.Hash == "" {
+		return "", fmt.Errorf("last entry of %s is not an audit entry; run `audit verify`", path)
+	}
+	return entry.Hash, nil
+}
+
+// VerifyAuditLog checks the hash chain of an audit log, returning the number of entries and the hash of the last one.
+// Entries cut off from the end leave a valid chain, so the last hash is worth keeping somewhere else to compare with.
+func VerifyAuditLog(r io.Reader) (int, string, error) {
+	count := 0
+	prevHash := AUDIT_GENESIS_HASH
+	scanner := newAuditScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		entry := AuditEntry{}
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&entry); err != nil {
+			return count, prevHash, fmt.Errorf("line %d: invalid entry: %w", lineNum, err)
+		}
+		if entry.PrevHash != prevHash {
+			return count, prevHash, fmt.Errorf("line %d: entry does not follow the one before it; entries were removed, inserted or reordered", lineNum)
+		}
+		expected, err := entry.ComputeHash()
+		if err != nil {
+			return count, prevHash, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		if entry.Hash != expected {
+			return count, prevHash, fmt.Errorf("line %d: hash mismatch; the entry was changed", lineNum)
+		}
+		count++
+		prevHash = entry.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		return count, prevHash, err
+	}
+	return count, prevHash, nil
+}
+
+func newAuditScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	// Entries are short, but changes to many peers at once carry long argument lists
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	return scanner
+}
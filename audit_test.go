@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func writeTestAuditLog(t *testing.T) (string, []string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	vlan := loadTestVLAN(t, "basic.yaml")
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	changes := []VLANChange{
+		{Command: "init", Args: []string{"wg-vlan", "10.20.30.1/24"}, Peers: vlan.allPeers()},
+		{Command: "client-add", Args: []string{"alice", "10.20.30.2"}, Peers: []ChangedPeer{changedClient(vlan.Client("alice"))}},
+		{Command: "migrate", Args: []string{"v1"}},
+	}
+	for idx, change := range changes {
+		if err := AppendAuditLog(path, change.auditEntries("filip", "/etc/wg-vlan/vlan.yaml", now.Add(time.Duration(idx)*time.Minute))); err != nil {
+			t.Fatalf("failed to append to audit log: %v", err)
+		}
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read audit log: %v", err)
+	}
+	return path, strings.SplitAfter(strings.TrimSuffix(string(content), "\n"), "\n")
+}
+
+func TestAuditLog(t *testing.T) {
+	path, lines := writeTestAuditLog(t)
+	// init records the server and its three clients, client-add alice, and migrate no peer
+	if len(lines) != 6 {
+		t.Fatalf("expected 6 entries, got %d:\n%s", len(lines), strings.Join(lines, ""))
+	}
+	for _, part := range []string{`"actor":"filip"`, `"command":"client-add"`, `"peer":"alice"`, `"fingerprint":"SHA256:`, `"prev_hash":"` + AUDIT_GENESIS_HASH} {
+		if !strings.Contains(strings.Join(lines, ""), part) {
+			t.Errorf("expected audit log to contain %s", part)
+		}
+	}
+
+	fp, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+	defer fp.Close()
+	count, lastHash, err := VerifyAuditLog(fp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 6 {
+		t.Errorf("expected 6 verified entries, got %d", count)
+	}
+	if _, err := fp.Seek(0, io.SeekStart); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected, _ := lastAuditHash(fp, path); lastHash != expected {
+		t.Errorf("expected last hash %s, got %s", expected, lastHash)
+	}
+}
+
+func TestVerifyAuditLogTampering(t *testing.T) {
+	_, lines := writeTestAuditLog(t)
+
+	for _, tc := range []struct {
+		name    string
+		lines   []string
+		errPart string
+	}{
+		{"changed", []string{lines[0], strings.Replace(lines[1], `"actor":"filip"`, `"actor":"mallory"`, 1)}, "line 2: hash mismatch"},
+		{"removed", []string{lines[0], lines[2]}, "line 2: entry does not follow"},
+		{"reordered", []string{lines[1], lines[0]}, "line 1: entry does not follow"},
+		{"added field", []string{strings.Replace(lines[0], `{`, `{"note":"x",`, 1)}, "line 1: invalid entry"},
+		{"garbage", []string{lines[0], "not json\n"}, "line 2: invalid entry"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := VerifyAuditLog(strings.NewReader(strings.Join(tc.lines, "")))
+			if err == nil || !strings.Contains(err.Error(), tc.errPart) {
+				t.Errorf("expected error containing %q, got %v", tc.errPart, err)
+			}
+		})
+	}
+}
+
+func TestChangeRecorderAuditLog(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "vlan.yaml")
+	recorder := ChangeRecorder{AuditLog: filepath.Join(t.TempDir(), "audit.jsonl"), Actor: "filip"}
+	change := VLANChange{Command: "client-remove", Args: []string{"bob"}, Peers: []ChangedPeer{{Name: "bob"}}}
+	if _, err := recorder.Record(configPath, change, time.Now(), nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	content, err := os.ReadFile(recorder.AuditLog)
+	if err != nil {
+		t.Fatalf("failed to read audit log: %v", err)
+	}
+	if !strings.Contains(string(content), `"config":"`+configPath+`"`) || strings.Contains(string(content), "fingerprint") {
+		t.Errorf("unexpected audit entry: %s", content)
+	}
+}
+
+func TestPublicKeyFingerprint(t *testing.T) {
+	fingerprint := PublicKeyFingerprint("ZK3+qu5D9HjC+GRRym7beOaIQDyHzfQnmnhS6o2653o=")
+	if !strings.HasPrefix(fingerprint, "SHA256:") || len(fingerprint) != len("SHA256:")+43 {
+		t.Errorf("unexpected fingerprint: %s", fingerprint)
+	}
+	if PublicKeyFingerprint("not a key") != "" {
+		t.Errorf("expected no fingerprint for an invalid key")
+	}
+}
+
+func TestAppendAuditLogConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// Without locking, writers would read the same last hash and fork the chain
+	wg := sync.WaitGroup{}
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			change := VLANChange{Command: "client-add", Args: []string{fmt.Sprintf("client%d", i)}}
+			errs <- AppendAuditLog(path, change.auditEntries("filip", "/etc/wg-vlan/vlan.yaml", now))
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	fp, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+	defer fp.Close()
+	if count, _, err := VerifyAuditLog(fp); err != nil || count != 50 {
+		t.Errorf("expected 50 chained entries, got %d: %v", count, err)
+	}
+}
+
+func TestAuditActor(t *testing.T) {
+	t.Setenv("USER", "root")
+	t.Setenv("SUDO_USER", "filip")
+	if actor := auditActor(""); actor != "filip" {
+		t.Errorf("expected the sudo user, got %s", actor)
+	}
+	if actor := auditActor("deploy-bot"); actor != "deploy-bot" {
+		t.Errorf("expected the given actor, got %s", actor)
+	}
+	t.Setenv("SUDO_USER", "")
+	if actor := auditActor(""); actor != "root" {
+		t.Errorf("expected $USER, got %s", actor)
+	}
+}
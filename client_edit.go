@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+)
+
+// RenameClient renames a client, along with the allow rules that name it
+func (vlan *VLAN) RenameClient(oldName string, newName string) error {
+	client := vlan.Client(oldName)
+	if client == nil {
+		return fmt.Errorf("no such client: %s", oldName)
+	}
+	if err := vlan.checkNameFree(newName); err != nil {
+		return err
+	}
+
+	client.PeerName = newName
+	for idx := range vlan.Allow {
+		for _, endpoints := range [][]string{vlan.Allow[idx].From, vlan.Allow[idx].To} {
+			for i, endpoint := range endpoints {
+				if endpoint == oldName {
+					endpoints[i] = newName
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ReaddressClient moves a client to the given address, which must be a free address in the VLAN's subnet. Without an
+// address, the client is moved to the next free one. The new address is returned.
+func (vlan *VLAN) ReaddressClient(name string, address string) (string, error) {
+	client := vlan.Client(name)
+	if client == nil {
+		return "", fmt.Errorf("no such client: %s", name)
+	}
+
+	if address == "" {
+		next, err := vlan.NextAddress()
+		if err != nil {
+			return "", err
+		}
+		client.Network = next.String()
+		return client.Network, nil
+	}
+
+	ip, ipNet, err := parseCIDR(address)
+	if err != nil {
+		return "", fmt.Errorf("invalid address '%s': %w", address, err)
+	}
+	serverIP, vlanNetwork, err := parseCIDR(vlan.Server.Network)
+	if err != nil {
+		return "", fmt.Errorf("server had invalid network '%s': %w", vlan.Server.Network, err)
+	}
+	if !vlanNetwork.Contains(ip) || ones(ipNet) < ones(vlanNetwork) {
+		return "", fmt.Errorf("address %s is outside the VLAN subnet %s", address, vlanNetwork)
+	}
+	if ipNet.Contains(serverIP) || ip.Equal(vlanNetwork.IP) {
+		return "", fmt.Errorf("address %s is reserved", address)
+	}
+	for _, other := range vlan.Clients {
+		if other == client {
+			continue
+		}
+		otherIP, otherNet, err := parseCIDR(other.Network)
+		if err != nil {
+			return "", fmt.Errorf("client '%s' had invalid network '%s': %w", other.PeerName, other.Network, err)
+		}
+		if otherNet.Contains(ip) || ipNet.Contains(otherIP) {
+			return "", fmt.Errorf("address %s is already in use by client %s", address, other.PeerName)
+		}
+	}
+	for _, invite := range vlan.Invites {
+		inviteIP, _, err := parseCIDR(invite.Network)
+		if err == nil && ipNet.Contains(inviteIP) {
+			return "", fmt.Errorf("address %s is reserved by an invite for %s", address, invite.PeerName)
+		}
+	}
+
+	client.Network = address
+	return client.Network, nil
+}
+
+// RedistributeAfterChange lists the exported configs that change along with a client's name or address, and so must
+// be exported and handed out again
+func (vlan VLAN) RedistributeAfterChange(clientName string) []string {
+	exports := []string{"the server config (export -s)"}
+	if client := vlan.Client(clientName); client != nil && client.PrivateKey != "" {
+		exports = append(exports, fmt.Sprintf("the config of client %s (export -c %s)", clientName, clientName))
+	} else {
+		exports = append(exports, fmt.Sprintf("the config of client %s, which keeps its own private key and must be updated by hand", clientName))
+	}
+	if len(vlan.Policy) > 0 || len(vlan.Allow) > 0 {
+		exports = append(exports, "the server firewall rules (export -s --format nftables or iptables)")
+	}
+	return exports
+}
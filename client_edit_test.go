@@ -0,0 +1,132 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenameClient(t *testing.T) {
+	vlan := loadTestVLAN(t, "allow.yaml")
+	if err := vlan.RenameClient("bob", "robert"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vlan.Client("bob") != nil || vlan.Client("robert") == nil {
+		t.Errorf("expected bob to be renamed to robert")
+	}
+	for _, rule := range vlan.Allow {
+		for _, endpoint := range append(rule.From, rule.To...) {
+			if endpoint == "bob" {
+				t.Errorf("expected allow rules to follow the rename, got %s", rule)
+			}
+		}
+	}
+	if _, err := vlan.Validate(); err != nil {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+
+	for _, tc := range []struct {
+		oldName   string
+		newName   string
+		errorPart string
+	}{
+		{"nobody", "someone", "no such client"},
+		{"robert", "alice", "name is already in use"},
+		{"robert", "", "empty name"},
+	} {
+		if err := vlan.RenameClient(tc.oldName, tc.newName); err == nil || !strings.Contains(err.Error(), tc.errorPart) {
+			t.Errorf("rename %s to %s: expected error containing '%s', got %v", tc.oldName, tc.newName, tc.errorPart, err)
+		}
+	}
+
+	if _, _, err := vlan.NewInvite("carol", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := vlan.RenameClient("robert", "carol"); err == nil || !strings.Contains(err.Error(), "reserved by an invite") {
+		t.Errorf("expected invited name to be refused, got %v", err)
+	}
+}
+
+func TestReaddressClient(t *testing.T) {
+	vlan := loadTestVLAN(t, "basic.yaml")
+	if _, _, err := vlan.NewInvite("carol", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, tc := range []struct {
+		address   string
+		errorPart string
+	}{
+		{"10.20.31.2", "outside the VLAN subnet"},
+		{"10.20.30.1", "reserved"},
+		{"10.20.30.0", "reserved"},
+		{"10.20.30.3", "in use by client bob"},
+		{"10.20.30.4/31", "in use by client phone"},
+		{"10.20.30.0/30", "reserved"},
+		{"10.20.30.5", "reserved by an invite for carol"},
+		{"10.20.30.300", "invalid address"},
+	} {
+		if _, err := vlan.ReaddressClient("alice", tc.address); err == nil || !strings.Contains(err.Error(), tc.errorPart) {
+			t.Errorf("address %s: expected error containing '%s', got %v", tc.address, tc.errorPart, err)
+		}
+	}
+	if vlan.Client("alice").Network != "10.20.30.2" {
+		t.Errorf("expected failed moves to leave alice in place, got %s", vlan.Client("alice").Network)
+	}
+
+	address, err := vlan.ReaddressClient("alice", "10.20.30.200")
+	if err != nil || address != "10.20.30.200" || vlan.Client("alice").Network != address {
+		t.Errorf("expected alice to move to 10.20.30.200, got %s: %v", address, err)
+	}
+	// Moving to the next free address skips the address reserved by the invite
+	address, err = vlan.ReaddressClient("bob", "")
+	if err != nil || address != "10.20.30.2" {
+		t.Errorf("expected bob to move to 10.20.30.2, got %s: %v", address, err)
+	}
+	if _, err := vlan.Validate(); err != nil {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+}
+
+func TestValidateDuplicateClientNames(t *testing.T) {
+	vlan := loadTestVLAN(t, "basic.yaml")
+	vlan.Clients[1].PeerName = "alice"
+	_, err := vlan.Validate()
+	if err == nil || !strings.Contains(err.Error(), "client[1] (line 15, column 3): non-unique client name") {
+		t.Errorf("expected duplicate name error, got %v", err)
+	}
+}
+
+func TestValidateDuplicatePublicKeys(t *testing.T) {
+	vlan := loadTestVLAN(t, "basic.yaml")
+	vlan.Clients[2].PublicKey = vlan.Clients[0].PublicKey
+	_, err := vlan.Validate()
+	if err == nil || !strings.Contains(err.Error(), "client[2] (line 20, column 3): public key already used by client 'alice'") {
+		t.Errorf("expected duplicate public key error, got %v", err)
+	}
+
+	// Keys derived from private keys count too, without being filled in
+	vlan = loadTestVLAN(t, "basic.yaml")
+	vlan.Clients[1].PrivateKey = vlan.Server.PrivateKey
+	vlan.Clients[1].PublicKey = ""
+	_, err = vlan.Validate()
+	if err == nil || !strings.Contains(err.Error(), "public key already used by server 'wg-vlan'") {
+		t.Errorf("expected duplicate public key error, got %v", err)
+	}
+	if vlan.Clients[1].PublicKey != "" {
+		t.Errorf("expected validation to leave the public key unset")
+	}
+}
+
+func TestRedistributeAfterChange(t *testing.T) {
+	vlan := loadTestVLAN(t, "allow.yaml")
+	exports := vlan.RedistributeAfterChange("bob")
+	if len(exports) != 3 || !strings.Contains(exports[1], "export -c bob") || !strings.Contains(exports[2], "firewall") {
+		t.Errorf("unexpected exports: %v", exports)
+	}
+	vlan = loadTestVLAN(t, "basic.yaml")
+	exports = vlan.RedistributeAfterChange("phone")
+	if len(exports) != 2 || !strings.Contains(exports[1], "keeps its own private key") {
+		t.Errorf("unexpected exports: %v", exports)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Expired reports whether the client's expiry has passed at the given time
+func (cl VLANClient) Expired(now time.Time) bool {
+	return cl.ExpiresAt != nil && !now.Before(*cl.ExpiresAt)
+}
+
+// metadataComments describes the client's owner, description, tags, and expiry as INI comment lines
+func (cl VLANClient) metadataComments() []string {
+	comments := []string{}
+	if cl.Owner != "" {
+		comments = append(comments, "# Owner: "+cl.Owner)
+	}
+	if cl.Description != "" {
+		comments = append(comments, "# Description: "+strings.Join(strings.Fields(cl.Description), " "))
+	}
+	if len(cl.Tags) > 0 {
+		comments = append(comments, "# Tags: "+strings.Join(cl.Tags, ", "))
+	}
+	if cl.ExpiresAt != nil {
+		comments = append(comments, "# Expires: "+cl.ExpiresAt.Format(time.RFC3339))
+	}
+	return comments
+}
+
+// ParseExpiry parses an expiry given as an RFC 3339 time, a date (midnight UTC), or a duration from now such as
+// "72h" or "30d"
+func ParseExpiry(value string, now time.Time) (time.Time, error) {
+	if expiry, err := time.Parse(time.RFC3339, value); err == nil {
+		return expiry, nil
+	}
+	if expiry, err := time.Parse(time.DateOnly, value); err == nil {
+		return expiry, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if count, err := strconv.ParseUint(days, 10, 32); err == nil {
+			return now.AddDate(0, 0, int(count)).UTC().Truncate(time.Second), nil
+		}
+	}
+	if duration, err := ParseDuration(value); err == nil {
+		return now.Add(duration).UTC().Truncate(time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid expiry '%s': expected a date, an RFC 3339 time, or a duration such as 30d", value)
+}
+
+// ParseDuration parses a positive duration, either in days such as "30d" or as understood by time.ParseDuration
+func ParseDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if count, err := strconv.ParseUint(days, 10, 16); err == nil && count > 0 {
+			return time.Duration(count) * 24 * time.Hour, nil
+		}
+	}
+	if duration, err := time.ParseDuration(value); err == nil && duration > 0 {
+		return duration, nil
+	}
+	return 0, fmt.Errorf("invalid duration '%s': expected a positive duration such as 30d or 12h", value)
+}
+
+// ClientFilter selects clients by tag and owner. An empty filter selects every client; otherwise a client must have
+// one of the tags, if any are given, and one of the owners, if any are given.
+type ClientFilter struct {
+	Tags   []string
+	Owners []string
+}
+
+func (filter ClientFilter) Empty() bool {
+	return len(filter.Tags) == 0 && len(filter.Owners) == 0
+}
+
+func (filter ClientFilter) Matches(client *VLANClient) bool {
+	if len(filter.Owners) > 0 && !slices.Contains(filter.Owners, client.Owner) {
+		return false
+	}
+	if len(filter.Tags) > 0 && !slices.ContainsFunc(client.Tags, func(tag string) bool {
+		return slices.Contains(filter.Tags, tag)
+	}) {
+		return false
+	}
+	return true
+}
+
+// FilterClients returns a copy of the VLAN with only the clients the filter selects. Allow rules lose the endpoints
+// naming clients that were left out, and rules left without sources or destinations are dropped.
+func (vlan VLAN) FilterClients(filter ClientFilter) VLAN {
+	clients := []*VLANClient{}
+	for _, client := range vlan.Clients {
+		if filter.Matches(client) {
+			clients = append(clients, client)
+		}
+	}
+	filtered := vlan
+	filtered.Clients = clients
+
+	allow := []PolicyRule{}
+	for _, rule := range vlan.Allow {
+		resolvable := func(endpoint string) bool {
+			_, err := filtered.resolveAllowEndpoint(endpoint)
+			return err == nil
+		}
+		rule.From = slices.DeleteFunc(slices.Clone(rule.From), func(endpoint string) bool { return !resolvable(endpoint) })
+		rule.To = slices.DeleteFunc(slices.Clone(rule.To), func(endpoint string) bool { return !resolvable(endpoint) })
+		if len(rule.From) > 0 && len(rule.To) > 0 {
+			allow = append(allow, rule)
+		}
+	}
+	filtered.Allow = allow
+	return filtered
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServerIniMetadataComments(t *testing.T) {
+	vlan := loadTestVLAN(t, "metadata.yaml")
+	iniFile, err := vlan.ServerIni()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out, err := IniString(iniFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertGolden(t, "metadata_server.ini", out)
+
+	// Exporters that name peers in their own comments only use the first comment line
+	script, err := vlan.ServerRouterOS("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(script, `comment="VLAN Client: alice"`) {
+		t.Errorf("expected RouterOS peer comment to name the client only, got:\n%s", script)
+	}
+}
+
+func TestValidateExpired(t *testing.T) {
+	vlan := loadTestVLAN(t, "metadata.yaml")
+	warnings, err := vlan.Validate()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.ContainsFunc(warnings, func(w string) bool {
+		return strings.HasPrefix(w, "client[1]") && strings.HasSuffix(w, "client expired at 2020-06-30T12:00:00Z")
+	}) {
+		t.Errorf("expected expiry warning for bob, got %v", warnings)
+	}
+	if slices.ContainsFunc(warnings, func(w string) bool {
+		return strings.HasPrefix(w, "client[0]") && strings.Contains(w, "expired")
+	}) {
+		t.Errorf("unexpected expiry warning for alice, got %v", warnings)
+	}
+}
+
+func TestParseExpiry(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		input    string
+		expected time.Time
+	}{
+		{"2024-12-31", time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{"2024-12-31T18:00:00+02:00", time.Date(2024, 12, 31, 16, 0, 0, 0, time.UTC)},
+		{"30d", time.Date(2024, 5, 31, 10, 30, 0, 0, time.UTC)},
+		{"36h", time.Date(2024, 5, 2, 22, 30, 0, 0, time.UTC)},
+	} {
+		expiry, err := ParseExpiry(tc.input, now)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.input, err)
+		} else if !expiry.Equal(tc.expected) {
+			t.Errorf("%s: expected %s, got %s", tc.input, tc.expected, expiry)
+		}
+	}
+
+	for _, input := range []string{"", "soon", "-5d", "-1h", "2024-13-01"} {
+		if _, err := ParseExpiry(input, now); err == nil {
+			t.Errorf("%q: expected error", input)
+		}
+	}
+}
+
+func TestFilterClients(t *testing.T) {
+	vlan := loadTestVLAN(t, "metadata.yaml")
+
+	for _, tc := range []struct {
+		name     string
+		filter   ClientFilter
+		expected []string
+	}{
+		{"empty", ClientFilter{}, []string{"alice", "bob", "printer"}},
+		{"tag", ClientFilter{Tags: []string{"phones"}}, []string{"bob"}},
+		{"any tag", ClientFilter{Tags: []string{"phones", "laptops"}}, []string{"alice", "bob"}},
+		{"owner", ClientFilter{Owners: []string{"alice@example.com"}}, []string{"alice"}},
+		{"tag and owner", ClientFilter{Tags: []string{"phones"}, Owners: []string{"alice@example.com"}}, []string{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			filtered := vlan.FilterClients(tc.filter)
+			names := []string{}
+			for _, client := range filtered.Clients {
+				names = append(names, client.PeerName)
+			}
+			if !slices.Equal(names, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, names)
+			}
+		})
+	}
+
+	if len(vlan.Clients) != 3 {
+		t.Errorf("filtering modified the VLAN")
+	}
+
+	// Allow rules are trimmed to the remaining clients, so firewall rules can still be built
+	filtered := vlan.FilterClients(ClientFilter{Tags: []string{"phones"}})
+	if len(filtered.Allow) != 0 {
+		t.Errorf("expected allow rule without destinations to be dropped, got %v", filtered.Allow)
+	}
+	if _, err := filtered.Nftables(); err != nil {
+		t.Errorf("unexpected error building filtered ruleset: %v", err)
+	}
+}
+
+func TestWriteToKeepsExpiryText(t *testing.T) {
+	vlan := loadTestVLAN(t, "metadata.yaml")
+	path := filepath.Join(t.TempDir(), "vlan.yaml")
+	if err := vlan.WriteTo(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(string(written), "expires_at: 2999-01-01\n") {
+		t.Errorf("expected the hand-written expiry date to be kept, got:\n%s", written)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+
+	"github.com/urfave/cli/v2"
+)
+
+type AuditCommand struct{}
+
+func (c *AuditCommand) Command() *cli.Command {
+	return &cli.Command{
+		Name:        "audit",
+		Description: "inspect the audit log written with the global --audit-log flag",
+		Subcommands: []*cli.Command{
+			{
+				Name:        "verify",
+				Description: "check the hash chain of an audit log, given as an argument or with the global --audit-log flag, and print the hash of its last entry",
+				ArgsUsage:   "[<log file>]",
+				Action:      c.Verify,
+			},
+		},
+	}
+}
+
+func (c *AuditCommand) Verify(ctx *cli.Context) error {
+	cLog := getLogger(ctx)
+
+	path := ctx.Args().First()
+	if path == "" {
+		path = globalFlags(ctx).Path(AUDIT_LOG_FLAG)
+	}
+	if path == "" || ctx.Args().Len() > 1 {
+		cLog.Fatalf("expected one argument: <log file>")
+	}
+
+	fp, err := os.Open(path)
+	if err != nil {
+		cLog.Fatalf("error: failed to open audit log: %s", err.Error())
+	}
+	defer fp.Close()
+
+	count, lastHash, err := VerifyAuditLog(fp)
+	if err != nil {
+		cLog.Fatalf("error: audit log %s failed verification after %d good entries: %s", path, count, err.Error())
+	}
+	cLog.Printf("verified %d entries of %s; last hash %s", count, path, lastHash)
+
+	return nil
+}
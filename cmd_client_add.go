@@ -1,13 +1,19 @@
 package main
 
 import (
+	"time"
+
 	"github.com/urfave/cli/v2"
 )
 
 type ClientAddCommand struct {
-	fConfigFile string
-	fClientName string
-	fPublicKey  string
+	fConfigFile  string
+	fClientName  string
+	fPublicKey   string
+	fOwner       string
+	fDescription string
+	fTags        cli.StringSlice
+	fExpires     string
 }
 
 func (c *ClientAddCommand) Command() *cli.Command {
@@ -38,6 +44,26 @@ func (c *ClientAddCommand) Command() *cli.Command {
 				DefaultText: "generate a new private/public pair",
 				Destination: &c.fPublicKey,
 			},
+			&cli.StringFlag{
+				Name:        "owner",
+				Usage:       "person or team responsible for the client",
+				Destination: &c.fOwner,
+			},
+			&cli.StringFlag{
+				Name:        "description",
+				Usage:       "free-form description of the client",
+				Destination: &c.fDescription,
+			},
+			&cli.StringSliceFlag{
+				Name:        "tag",
+				Usage:       "label for selecting the client in exports; may be repeated",
+				Destination: &c.fTags,
+			},
+			&cli.StringFlag{
+				Name:        "expires",
+				Usage:       "expiry of the client, as a date, an RFC 3339 time, or a duration from now such as 30d",
+				Destination: &c.fExpires,
+			},
 		},
 	}
 }
@@ -60,13 +86,30 @@ func (c *ClientAddCommand) Action(ctx *cli.Context) error {
 		cLog.Fatalf("failed to create client: %s", err.Error())
 	}
 
+	newClient.Owner = c.fOwner
+	newClient.Description = c.fDescription
+	newClient.Tags = c.fTags.Value()
+	if c.fExpires != "" {
+		expiry, err := ParseExpiry(c.fExpires, time.Now())
+		if err != nil {
+			cLog.Fatalf("error: %s", err.Error())
+		}
+		newClient.ExpiresAt = &expiry
+	}
+	if _, err := newClient.Validate(); err != nil {
+		cLog.Fatalf("failed to create client: %s", err.Error())
+	}
+
 	cLog.Printf("successfully created client: %s - %s", newClient.PeerName, newClient.Network)
 
-	if err := vlan.WriteTo(c.fConfigFile); err != nil {
+	written, err := saveVLAN(ctx, vlan, c.fConfigFile, VLANChange{Command: "client-add", Args: []string{newClient.PeerName, newClient.Network}, Peers: []ChangedPeer{changedClient(newClient)}})
+	if err != nil {
 		cLog.Fatalf("error: failed to write config file: %s", err.Error())
 	}
 
-	cLog.Printf("wrote configuration to: %s", c.fConfigFile)
+	if written {
+		cLog.Printf("wrote configuration to: %s", c.fConfigFile)
+	}
 
 	return nil
 }
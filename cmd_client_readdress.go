@@ -0,0 +1,86 @@
+package main
+
+import (
+	"github.com/urfave/cli/v2"
+)
+
+type ClientReaddressCommand struct {
+	fConfigFile string
+	fClientName string
+	fAddress    string
+	fAuto       bool
+}
+
+func (c *ClientReaddressCommand) Command() *cli.Command {
+	return &cli.Command{
+		Name:        "client-readdress",
+		Aliases:     []string{"readdress"},
+		Description: "move a client to another address in the VLAN subnet",
+		Args:        false,
+		Action:      c.Action,
+		Flags: []cli.Flag{
+			&cli.PathFlag{
+				Name:        "vlan-config",
+				Aliases:     []string{"f"},
+				Usage:       "YAML config file to write to",
+				Required:    true,
+				Destination: &c.fConfigFile,
+			},
+			&cli.StringFlag{
+				Name:        "client-name",
+				Aliases:     []string{"name", "n"},
+				Usage:       "name of client to move",
+				Required:    true,
+				Destination: &c.fClientName,
+			},
+			&cli.StringFlag{
+				Name:        "address",
+				Usage:       "free address in the VLAN subnet to move the client to",
+				Destination: &c.fAddress,
+			},
+			&cli.BoolFlag{
+				Name:        "auto",
+				Usage:       "move the client to the next free address",
+				Destination: &c.fAuto,
+			},
+		},
+	}
+}
+
+func (c *ClientReaddressCommand) Action(ctx *cli.Context) error {
+	cLog := getLogger(ctx)
+
+	if (c.fAddress == "") == !c.fAuto {
+		cLog.Fatalf("must specify exactly one of --address or --auto")
+	}
+
+	vlan, err := VLANFromFile(c.fConfigFile, cLog)
+	if err != nil {
+		cLog.Fatalf("error: %s", err.Error())
+	}
+
+	client := vlan.Client(c.fClientName)
+	if client == nil {
+		cLog.Fatalf("no such client: %s", c.fClientName)
+	}
+	oldAddress := client.Network
+
+	newAddress, err := vlan.ReaddressClient(c.fClientName, c.fAddress)
+	if err != nil {
+		cLog.Fatalf("failed to readdress client: %s", err.Error())
+	}
+	if _, err := vlan.Validate(); err != nil {
+		cLog.Fatalf("failed to readdress client: %s", err.Error())
+	}
+
+	if _, err := saveVLAN(ctx, vlan, c.fConfigFile, VLANChange{Command: "client-readdress", Args: []string{c.fClientName, newAddress}, Peers: []ChangedPeer{changedClient(client)}}); err != nil {
+		cLog.Fatalf("error: failed to write config file: %s", err.Error())
+	}
+	cLog.Printf("moved client %s from %s to %s", c.fClientName, oldAddress, newAddress)
+
+	for _, export := range vlan.RedistributeAfterChange(c.fClientName) {
+		cLog.Printf("redistribute %s", export)
+	}
+
+	return nil
+}
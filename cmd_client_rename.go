@@ -0,0 +1,61 @@
+package main
+
+import (
+	"github.com/urfave/cli/v2"
+)
+
+type ClientRenameCommand struct {
+	fConfigFile string
+}
+
+func (c *ClientRenameCommand) Command() *cli.Command {
+	return &cli.Command{
+		Name:        "client-rename",
+		Aliases:     []string{"rename"},
+		Description: "rename a client, along with the allow rules that name it",
+		ArgsUsage:   "<old name> <new name>",
+		Args:        true,
+		Action:      c.Action,
+		Flags: []cli.Flag{
+			&cli.PathFlag{
+				Name:        "vlan-config",
+				Aliases:     []string{"f"},
+				Usage:       "YAML config file to write to",
+				Required:    true,
+				Destination: &c.fConfigFile,
+			},
+		},
+	}
+}
+
+func (c *ClientRenameCommand) Action(ctx *cli.Context) error {
+	cLog := getLogger(ctx)
+
+	if ctx.Args().Len() != 2 {
+		cLog.Fatalf("expected two arguments: <old name> <new name>")
+	}
+	oldName, newName := ctx.Args().Get(0), ctx.Args().Get(1)
+
+	vlan, err := VLANFromFile(c.fConfigFile, cLog)
+	if err != nil {
+		cLog.Fatalf("error: %s", err.Error())
+	}
+
+	if err := vlan.RenameClient(oldName, newName); err != nil {
+		cLog.Fatalf("failed to rename client: %s", err.Error())
+	}
+	if _, err := vlan.Validate(); err != nil {
+		cLog.Fatalf("failed to rename client: %s", err.Error())
+	}
+
+	if _, err := saveVLAN(ctx, vlan, c.fConfigFile, VLANChange{Command: "client-rename", Args: []string{oldName, newName}, Peers: []ChangedPeer{changedClient(vlan.Client(newName))}}); err != nil {
+		cLog.Fatalf("error: failed to write config file: %s", err.Error())
+	}
+	cLog.Printf("renamed client %s to %s", oldName, newName)
+
+	for _, export := range vlan.RedistributeAfterChange(newName) {
+		cLog.Printf("redistribute %s", export)
+	}
+
+	return nil
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"fmt"
-	"strings"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
 
 	"github.com/skip2/go-qrcode"
 	"github.com/urfave/cli/v2"
@@ -14,6 +17,17 @@ type PrintIniCommand struct {
 	fServerOutput bool
 	fClientOutput string
 	fFormat       string
+	fOutputDir    string
+	fKeyDir       string
+	fAutoconnect  bool
+	fWanGateway   string
+	fAll          bool
+	fK8sName      string
+	fK8sNamespace string
+	fK8sLabels    cli.StringSlice
+	fRedact       bool
+	fTags         cli.StringSlice
+	fOwners       cli.StringSlice
 }
 
 func (c *PrintIniCommand) Command() *cli.Command {
@@ -49,7 +63,66 @@ func (c *PrintIniCommand) Command() *cli.Command {
 					Destination: &c.fFormat,
 					Value:       "text",
 				},
-				Choices: []string{"text", "qr"},
+				Choices: []string{"text", "qr", "nftables", "iptables", "networkd", "nmconnection", "uci", "routeros", "k8s-secret", "json"},
+			},
+			&cli.PathFlag{
+				Name:        "output-dir",
+				Aliases:     []string{"o"},
+				Usage:       "write the files of multi-file formats (networkd) to this directory, including key files",
+				DefaultText: "print to stdout, without key files",
+				Destination: &c.fOutputDir,
+			},
+			&cli.StringFlag{
+				Name:        "key-dir",
+				Usage:       "directory that exported networkd configs reference key files in",
+				Value:       DEFAULT_NETWORKD_KEY_DIR,
+				Destination: &c.fKeyDir,
+			},
+			&cli.BoolFlag{
+				Name:        "autoconnect",
+				Usage:       "make exported nmconnection configs connect automatically",
+				Destination: &c.fAutoconnect,
+			},
+			&cli.StringFlag{
+				Name:        "wan-gateway",
+				Usage:       "upstream gateway that exported routeros configs route the endpoint through when a peer takes all traffic",
+				Destination: &c.fWanGateway,
+			},
+			&cli.BoolFlag{
+				Name:        "all",
+				Usage:       "export the server and every client with a private key together (k8s-secret)",
+				Destination: &c.fAll,
+			},
+			&cli.StringFlag{
+				Name:        "k8s-name",
+				Usage:       "name of the exported Kubernetes Secret",
+				DefaultText: "derived from the peer names",
+				Destination: &c.fK8sName,
+			},
+			&cli.StringFlag{
+				Name:        "k8s-namespace",
+				Usage:       "namespace of the exported Kubernetes Secret",
+				Destination: &c.fK8sNamespace,
+			},
+			&cli.StringSliceFlag{
+				Name:        "k8s-label",
+				Usage:       "key=value label to add to the exported Kubernetes Secret",
+				Destination: &c.fK8sLabels,
+			},
+			&cli.StringSliceFlag{
+				Name:        "tag",
+				Usage:       "only export clients with this tag; may be repeated to match any of several",
+				Destination: &c.fTags,
+			},
+			&cli.StringSliceFlag{
+				Name:        "owner",
+				Usage:       "only export clients with this owner; may be repeated to match any of several",
+				Destination: &c.fOwners,
+			},
+			&cli.BoolFlag{
+				Name:        "redact",
+				Usage:       "leave private and preshared keys out of the exported VLAN (json)",
+				Destination: &c.fRedact,
 			},
 		},
 	}
@@ -61,6 +134,22 @@ func (c *PrintIniCommand) Action(ctx *cli.Context) error {
 		return c.printText(ctx)
 	case "qr":
 		return c.printQR(ctx)
+	case "nftables":
+		return c.printNftables(ctx)
+	case "iptables":
+		return c.printIptables(ctx)
+	case "networkd":
+		return c.printNetworkd(ctx)
+	case "nmconnection":
+		return c.printNMConnection(ctx)
+	case "uci":
+		return c.printUCI(ctx)
+	case "routeros":
+		return c.printRouterOS(ctx)
+	case "k8s-secret":
+		return c.printK8sSecret(ctx)
+	case "json":
+		return c.printJSON(ctx)
 	}
 	return fmt.Errorf("unknown format: '%s'", c.fFormat)
 }
@@ -75,12 +164,10 @@ func (c *PrintIniCommand) printText(ctx *cli.Context) error {
 		cLog.Fatalf("must specify either --server or --client")
 	}
 
-	vlan, err := VLANFromFile(c.fConfigFile, cLog)
-	if err != nil {
-		cLog.Fatalf("error reading config: %s", err.Error())
-	}
+	vlan := c.loadVLAN(ctx)
 
 	var iniFile *ini.File
+	var err error
 
 	if c.fServerOutput {
 		iniFile, err = vlan.ServerIni()
@@ -91,7 +178,7 @@ func (c *PrintIniCommand) printText(ctx *cli.Context) error {
 		cLog.Fatalf("error building ini: %s", err.Error())
 	}
 
-	if _, err := iniFile.WriteTo(ctx.App.Writer); err != nil {
+	if err := WriteIni(ctx.App.Writer, iniFile); err != nil {
 		cLog.Fatalf("error writing ini: %s", err.Error())
 	}
 
@@ -108,12 +195,10 @@ func (c *PrintIniCommand) printQR(ctx *cli.Context) error {
 		cLog.Fatalf("must specify either --server or --client")
 	}
 
-	vlan, err := VLANFromFile(c.fConfigFile, cLog)
-	if err != nil {
-		cLog.Fatalf("error reading config: %s", err.Error())
-	}
+	vlan := c.loadVLAN(ctx)
 
 	var iniFile *ini.File
+	var err error
 
 	if c.fServerOutput {
 		iniFile, err = vlan.ServerIni()
@@ -124,16 +209,331 @@ func (c *PrintIniCommand) printQR(ctx *cli.Context) error {
 		cLog.Fatalf("error building ini: %s", err.Error())
 	}
 
-	buf := strings.Builder{}
-
-	if _, err := iniFile.WriteTo(&buf); err != nil {
+	iniText, err := IniString(iniFile)
+	if err != nil {
 		cLog.Fatalf("error writing ini: %s", err.Error())
 	}
 
-	qr, err := qrcode.New(buf.String(), qrcode.Low)
+	qr, err := qrcode.New(iniText, qrcode.Low)
 	if err != nil {
 		cLog.Fatalf("error constructing QR: %s", err.Error())
 	}
 	fmt.Fprintln(ctx.App.Writer, qr.ToSmallString(false))
 	return nil
 }
+
+func (c *PrintIniCommand) printNftables(ctx *cli.Context) error {
+	cLog := getLogger(ctx)
+
+	if c.fClientOutput != "" {
+		cLog.Fatalf("nftables rulesets are only available for the server")
+	}
+
+	vlan := c.loadVLAN(ctx)
+
+	ruleset, err := vlan.Nftables()
+	if err != nil {
+		cLog.Fatalf("error building nftables ruleset: %s", err.Error())
+	}
+
+	fmt.Fprint(ctx.App.Writer, ruleset)
+	return nil
+}
+
+func (c *PrintIniCommand) printIptables(ctx *cli.Context) error {
+	cLog := getLogger(ctx)
+
+	if c.fClientOutput != "" {
+		cLog.Fatalf("iptables rulesets are only available for the server")
+	}
+
+	vlan := c.loadVLAN(ctx)
+
+	ruleset, err := vlan.Iptables()
+	if err != nil {
+		cLog.Fatalf("error building iptables ruleset: %s", err.Error())
+	}
+
+	fmt.Fprint(ctx.App.Writer, ruleset)
+	return nil
+}
+
+func (c *PrintIniCommand) printNetworkd(ctx *cli.Context) error {
+	cLog := getLogger(ctx)
+
+	if c.fClientOutput != "" {
+		cLog.Fatalf("networkd configs are only available for the server")
+	}
+
+	vlan := c.loadVLAN(ctx)
+
+	files, err := vlan.Networkd(c.fKeyDir)
+	if err != nil {
+		cLog.Fatalf("error building networkd configs: %s", err.Error())
+	}
+
+	c.writeFiles(ctx, files)
+	return nil
+}
+
+func (c *PrintIniCommand) printNMConnection(ctx *cli.Context) error {
+	cLog := getLogger(ctx)
+
+	if c.fClientOutput == "" {
+		cLog.Fatalf("NetworkManager connections are only available for clients; specify --client")
+	}
+
+	vlan := c.loadVLAN(ctx)
+
+	connection, err := vlan.NMConnection(c.fClientOutput, c.fAutoconnect)
+	if err != nil {
+		cLog.Fatalf("error building NetworkManager connection: %s", err.Error())
+	}
+
+	fmt.Fprint(ctx.App.Writer, connection)
+	return nil
+}
+
+func (c *PrintIniCommand) printUCI(ctx *cli.Context) error {
+	cLog := getLogger(ctx)
+
+	if c.fClientOutput == "" {
+		cLog.Fatalf("OpenWrt UCI scripts are only available for clients; specify --client")
+	}
+
+	vlan := c.loadVLAN(ctx)
+
+	script, warnings, err := vlan.UCI(c.fClientOutput)
+	if err != nil {
+		cLog.Fatalf("error building UCI script: %s", err.Error())
+	}
+	for _, warning := range warnings {
+		cLog.Printf("warning: %s", warning)
+	}
+
+	fmt.Fprint(ctx.App.Writer, script)
+	return nil
+}
+
+func (c *PrintIniCommand) printRouterOS(ctx *cli.Context) error {
+	cLog := getLogger(ctx)
+
+	if c.fServerOutput && c.fClientOutput != "" {
+		cLog.Fatalf("cannot output both server and client configs at once")
+	}
+	if !c.fServerOutput && c.fClientOutput == "" {
+		cLog.Fatalf("must specify either --server or --client")
+	}
+
+	vlan := c.loadVLAN(ctx)
+
+	var script string
+	var err error
+	if c.fServerOutput {
+		script, err = vlan.ServerRouterOS(c.fWanGateway)
+	} else {
+		script, err = vlan.ClientRouterOS(c.fClientOutput, c.fWanGateway)
+	}
+	if err != nil {
+		cLog.Fatalf("error building RouterOS script: %s", err.Error())
+	}
+
+	fmt.Fprint(ctx.App.Writer, script)
+	return nil
+}
+
+func (c *PrintIniCommand) printK8sSecret(ctx *cli.Context) error {
+	cLog := getLogger(ctx)
+
+	selected := 0
+	for _, isSelected := range []bool{c.fServerOutput, c.fClientOutput != "", c.fAll} {
+		if isSelected {
+			selected++
+		}
+	}
+	if selected != 1 {
+		cLog.Fatalf("must specify exactly one of --server, --client, or --all")
+	}
+
+	labels, err := ParseK8sLabels(c.fK8sLabels.Value())
+	if err != nil {
+		cLog.Fatalf("error: %s", err.Error())
+	}
+
+	vlan := c.loadVLAN(ctx)
+
+	// A single peer's config is keyed by interface name, as wg-quick expects; with --all, configs are keyed by peer
+	files := []ExportFile{}
+	name := K8sName(vlan.Server.PeerName)
+	switch {
+	case c.fServerOutput:
+		files = append(files, c.iniExportFile(ctx, interfaceFileName(vlan.Server.Interface), vlan.ServerIni))
+	case c.fClientOutput != "":
+		client := vlan.Client(c.fClientOutput)
+		if client == nil {
+			cLog.Fatalf("no such client: %s", c.fClientOutput)
+		}
+		name = K8sName(vlan.Server.PeerName + "-" + client.PeerName)
+		files = append(files, c.iniExportFile(ctx, interfaceFileName(client.Interface), func() (*ini.File, error) {
+			return vlan.ClientIni(client.PeerName)
+		}))
+	default:
+		files = append(files, c.iniExportFile(ctx, vlan.Server.PeerName+".conf", vlan.ServerIni))
+		for _, client := range vlan.Clients {
+			if client.PrivateKey == "" {
+				cLog.Printf("skipping client without private key: %s", client.PeerName)
+				continue
+			}
+			if client.Disabled {
+				cLog.Printf("skipping disabled client: %s", client.PeerName)
+				continue
+			}
+			files = append(files, c.iniExportFile(ctx, client.PeerName+".conf", func() (*ini.File, error) {
+				return vlan.ClientIni(client.PeerName)
+			}))
+		}
+	}
+	if c.fK8sName != "" {
+		name = c.fK8sName
+	}
+
+	manifest, err := K8sSecret(K8sSecretMeta{Name: name, Namespace: c.fK8sNamespace, Labels: labels}, files)
+	if err != nil {
+		cLog.Fatalf("error building Kubernetes Secret: %s", err.Error())
+	}
+
+	fmt.Fprint(ctx.App.Writer, manifest)
+	return nil
+}
+
+func (c *PrintIniCommand) printJSON(ctx *cli.Context) error {
+	cLog := getLogger(ctx)
+
+	if c.fServerOutput || c.fClientOutput != "" {
+		cLog.Fatalf("JSON output covers the whole VLAN; do not specify --server or --client")
+	}
+
+	vlan := c.loadVLAN(ctx)
+
+	out, err := vlan.JSON(c.fRedact)
+	if err != nil {
+		cLog.Fatalf("error building JSON: %s", err.Error())
+	}
+
+	if _, err := ctx.App.Writer.Write(out); err != nil {
+		cLog.Fatalf("error writing JSON: %s", err.Error())
+	}
+	return nil
+}
+
+// loadVLAN reads the VLAN config, keeping only the clients selected by --tag and --owner
+func (c *PrintIniCommand) loadVLAN(ctx *cli.Context) *VLAN {
+	cLog := getLogger(ctx)
+
+	vlan, err := VLANFromFile(c.fConfigFile, cLog)
+	if err != nil {
+		cLog.Fatalf("error reading config: %s", err.Error())
+	}
+
+	filter := ClientFilter{Tags: c.fTags.Value(), Owners: c.fOwners.Value()}
+	if filter.Empty() {
+		return vlan
+	}
+	if client := vlan.Client(c.fClientOutput); client != nil && !filter.Matches(client) {
+		cLog.Fatalf("client does not match --tag/--owner: %s", c.fClientOutput)
+	}
+	filtered := vlan.FilterClients(filter)
+	return &filtered
+}
+
+// iniExportFile builds an INI config and renders it as an export file
+func (c *PrintIniCommand) iniExportFile(ctx *cli.Context, name string, build func() (*ini.File, error)) ExportFile {
+	cLog := getLogger(ctx)
+
+	iniFile, err := build()
+	if err != nil {
+		cLog.Fatalf("error building ini: %s", err.Error())
+	}
+	content, err := IniString(iniFile)
+	if err != nil {
+		cLog.Fatalf("error writing ini: %s", err.Error())
+	}
+	return ExportFile{Name: name, Content: content, Secret: true}
+}
+
+// interfaceFileName names a wg-quick config so that wg-quick brings up the configured interface
+func interfaceFileName(iface string) string {
+	if iface == "" {
+		iface = DEFAULT_INTERFACE
+	}
+	return iface + ".conf"
+}
+
+// writeFiles writes the files of a multi-file export to the output directory if one was given, or otherwise prints
+// the non-secret ones
+func (c *PrintIniCommand) writeFiles(ctx *cli.Context, files []ExportFile) {
+	cLog := getLogger(ctx)
+
+	if c.fOutputDir == "" {
+		for idx, f := range files {
+			if f.Secret {
+				cLog.Printf("not printing secret file %s; use --output-dir to write it", f.Name)
+				continue
+			}
+			if idx > 0 {
+				fmt.Fprintln(ctx.App.Writer)
+			}
+			fmt.Fprintf(ctx.App.Writer, "# File: %s\n%s", f.Name, f.Content)
+		}
+		return
+	}
+
+	if err := os.MkdirAll(c.fOutputDir, 0755); err != nil {
+		cLog.Fatalf("error creating output directory: %s", err.Error())
+	}
+	for _, f := range files {
+		outputPath, mode, err := writeExportFile(c.fOutputDir, f)
+		if err != nil {
+			cLog.Fatalf("error writing %s: %s", outputPath, err.Error())
+		}
+		if f.Group == "" {
+			cLog.Printf("wrote %s (mode %04o)", outputPath, mode)
+			continue
+		}
+		if err := chgrpExportFile(outputPath, f.Group); err != nil {
+			cLog.Printf("warning: wrote %s (mode %04o) but could not give it to group %s (%s); run `chgrp %s %s` as root", outputPath, mode, f.Group, err.Error(), f.Group, outputPath)
+			continue
+		}
+		cLog.Printf("wrote %s (mode %04o, group %s)", outputPath, mode, f.Group)
+	}
+}
+
+// writeExportFile writes f to dir with the mode it calls for. A file that already exists is replaced rather than
+// written over, so that a key never lands in a file that is still readable with the old file's mode.
+func writeExportFile(dir string, f ExportFile) (string, os.FileMode, error) {
+	outputPath := filepath.Join(dir, f.Name)
+	if f.Name != filepath.Base(f.Name) || f.Name == "." || f.Name == ".." {
+		return outputPath, 0, fmt.Errorf("file name '%s' would leave the output directory", f.Name)
+	}
+
+	var mode os.FileMode = 0644
+	if f.Secret && f.Group != "" {
+		mode = 0640
+	} else if f.Secret {
+		mode = 0600
+	}
+	return outputPath, mode, writeFileAtomic(outputPath, []byte(f.Content), mode)
+}
+
+// chgrpExportFile gives a file to the named group, which usually requires root
+func chgrpExportFile(path string, group string) error {
+	grp, err := user.LookupGroup(group)
+	if err != nil {
+		return err
+	}
+	gid, err := strconv.Atoi(grp.Gid)
+	if err != nil {
+		return err
+	}
+	return os.Chown(path, -1, gid)
+}
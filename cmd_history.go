@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v2"
+)
+
+const HISTORY_DATE_FORMAT = "2006-01-02 15:04"
+
+type HistoryCommand struct {
+	fConfigFile string
+	fLimit      int
+}
+
+func (c *HistoryCommand) Command() *cli.Command {
+	return &cli.Command{
+		Name:        "history",
+		Description: "show the changes to a VLAN config committed into git with the global --git flag, newest first",
+		Args:        false,
+		Action:      c.Action,
+		Flags: []cli.Flag{
+			&cli.PathFlag{
+				Name:        "vlan-config",
+				Aliases:     []string{"f"},
+				Usage:       "YAML config file to show the history of",
+				Required:    true,
+				Destination: &c.fConfigFile,
+			},
+			&cli.IntFlag{
+				Name:        "limit",
+				Aliases:     []string{"l"},
+				Usage:       "number of changes to show; 0 shows all of them",
+				Value:       20,
+				Destination: &c.fLimit,
+			},
+		},
+	}
+}
+
+func (c *HistoryCommand) Action(ctx *cli.Context) error {
+	cLog := getLogger(ctx)
+
+	entries, err := ConfigHistory(c.fConfigFile, c.fLimit)
+	if err != nil {
+		cLog.Fatalf("error: %s", err.Error())
+	}
+	if len(entries) == 0 {
+		cLog.Printf("no changes committed to %s yet", c.fConfigFile)
+		return nil
+	}
+
+	table := tabwriter.NewWriter(ctx.App.Writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "COMMIT\tDATE\tAUTHOR\tCHANGE")
+	for _, entry := range entries {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", entry.Hash[:8], entry.When.Local().Format(HISTORY_DATE_FORMAT), entry.Author, entry.Message)
+	}
+	if err := table.Flush(); err != nil {
+		cLog.Fatalf("error: %s", err.Error())
+	}
+
+	return nil
+}
@@ -92,6 +92,7 @@ func (c *InitializeCommand) Action(ctx *cli.Context) error {
 	}
 
 	vlan := VLAN{
+		Version:        CONFIG_VERSION,
 		PublicEndpoint: c.fEndpoint,
 		KeepAlive:      DEFAULT_KEEP_ALIVE,
 		Server: VLANServer{
@@ -117,11 +118,14 @@ func (c *InitializeCommand) Action(ctx *cli.Context) error {
 		cLog.Fatalf("error: config already exists: %s", c.fConfigFile)
 	}
 
-	if err := vlan.WriteTo(c.fConfigFile); err != nil {
+	written, err := saveVLAN(ctx, &vlan, c.fConfigFile, VLANChange{Command: "init", Args: []string{vlan.Server.PeerName, vlan.Server.Network}, Peers: vlan.allPeers()})
+	if err != nil {
 		cLog.Fatalf("error: failed to write config file: %s", err.Error())
 	}
 
-	cLog.Printf("wrote configuration to: %s", c.fConfigFile)
+	if written {
+		cLog.Printf("wrote configuration to: %s", c.fConfigFile)
+	}
 
 	return nil
 }
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+const DEFAULT_INVITE_EXPIRY = "7d"
+
+type InviteCommand struct {
+	fConfigFile string
+	fClientName string
+	fExpires    string
+}
+
+func (c *InviteCommand) Command() *cli.Command {
+	return &cli.Command{
+		Name:        "invite",
+		Description: "reserve a client name and address, and print a one-time token for the client to redeem with its own key",
+		Args:        false,
+		Action:      c.Action,
+		Flags: []cli.Flag{
+			&cli.PathFlag{
+				Name:        "vlan-config",
+				Aliases:     []string{"f"},
+				Usage:       "YAML config file to write to",
+				Required:    true,
+				Destination: &c.fConfigFile,
+			},
+			&cli.StringFlag{
+				Name:        "client-name",
+				Aliases:     []string{"name", "n"},
+				Usage:       "name of the client to invite",
+				Required:    true,
+				Destination: &c.fClientName,
+			},
+			&cli.StringFlag{
+				Name:        "expires",
+				Usage:       "expiry of the token, as a date, an RFC 3339 time, or a duration from now such as 30d",
+				Value:       DEFAULT_INVITE_EXPIRY,
+				Destination: &c.fExpires,
+			},
+		},
+	}
+}
+
+func (c *InviteCommand) Action(ctx *cli.Context) error {
+	cLog := getLogger(ctx)
+
+	vlan, err := VLANFromFile(c.fConfigFile, cLog)
+	if err != nil {
+		cLog.Fatalf("error: %s", err.Error())
+	}
+
+	expiry, err := ParseExpiry(c.fExpires, time.Now())
+	if err != nil {
+		cLog.Fatalf("error: %s", err.Error())
+	}
+
+	invite, token, err := vlan.NewInvite(c.fClientName, expiry)
+	if err != nil {
+		cLog.Fatalf("failed to create invite: %s", err.Error())
+	}
+	if _, err := vlan.Validate(); err != nil {
+		cLog.Fatalf("failed to create invite: %s", err.Error())
+	}
+
+	written, err := saveVLAN(ctx, vlan, c.fConfigFile, VLANChange{Command: "invite", Args: []string{invite.PeerName, invite.Network}, Peers: []ChangedPeer{{Name: invite.PeerName}}})
+	if err != nil {
+		cLog.Fatalf("error: failed to write config file: %s", err.Error())
+	}
+	if !written {
+		// A token for an invite that was not saved could not be redeemed
+		return nil
+	}
+
+	cLog.Printf("reserved %s for client %s until %s", invite.Network, invite.PeerName, invite.ExpiresAt.Format(time.RFC3339))
+	fmt.Fprintln(ctx.App.Writer, token)
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+type MigrateCommand struct {
+	fConfigFile string
+	fBackupFile string
+}
+
+func (c *MigrateCommand) Command() *cli.Command {
+	return &cli.Command{
+		Name:        "migrate",
+		Description: "upgrade a VLAN config file to the current config version in place, keeping a backup",
+		Args:        false,
+		Action:      c.Action,
+		Flags: []cli.Flag{
+			&cli.PathFlag{
+				Name:        "vlan-config",
+				Aliases:     []string{"f"},
+				Usage:       "YAML config file to migrate",
+				Required:    true,
+				Destination: &c.fConfigFile,
+			},
+			&cli.PathFlag{
+				Name:        "backup",
+				Usage:       "file to copy the original config to",
+				DefaultText: "the config path with a .v<version>.bak suffix",
+				Destination: &c.fBackupFile,
+			},
+		},
+	}
+}
+
+func (c *MigrateCommand) Action(ctx *cli.Context) error {
+	cLog := getLogger(ctx)
+
+	if globalDryRun(ctx) {
+		original, migrated, applied, err := MigrateConfig(c.fConfigFile)
+		if err != nil {
+			cLog.Fatalf("error: %s", err.Error())
+		}
+		for _, migration := range applied {
+			cLog.Printf("[dry run] would apply migration %s", migration)
+		}
+		printDryRun(ctx, UnifiedDiff(diffLabel("a/", c.fConfigFile), diffLabel("b/", c.fConfigFile), string(original), string(migrated)), c.fConfigFile)
+		return nil
+	}
+
+	backupFile := c.fBackupFile
+	if backupFile == "" {
+		version, err := ConfigFileVersion(c.fConfigFile)
+		if err != nil {
+			cLog.Fatalf("error: %s", err.Error())
+		}
+		backupFile = fmt.Sprintf("%s.v%d.bak", c.fConfigFile, version)
+	}
+
+	migrations, err := MigrateConfigFile(c.fConfigFile, backupFile)
+	if err != nil {
+		cLog.Fatalf("error: %s", err.Error())
+	}
+	if len(migrations) == 0 {
+		cLog.Printf("config is already at version %d: %s", CONFIG_VERSION, c.fConfigFile)
+		return nil
+	}
+
+	for _, migration := range migrations {
+		cLog.Printf("applied migration %s", migration)
+	}
+	cLog.Printf("backed up original config to: %s", backupFile)
+	cLog.Printf("wrote configuration to: %s", c.fConfigFile)
+	recordChange(ctx, c.fConfigFile, VLANChange{Command: "migrate", Args: []string{fmt.Sprintf("v%d", CONFIG_VERSION)}})
+
+	return nil
+}
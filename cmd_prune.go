@@ -0,0 +1,133 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+type PruneCommand struct {
+	fConfigFile  string
+	fWgDump      string
+	fInactiveFor string
+	fRemove      bool
+	fDryRun      bool
+}
+
+func (c *PruneCommand) Command() *cli.Command {
+	return &cli.Command{
+		Name:        "prune",
+		Description: "disable or remove clients that have expired, or whose last handshake is too old",
+		Args:        false,
+		Action:      c.Action,
+		Flags: []cli.Flag{
+			&cli.PathFlag{
+				Name:        "vlan-config",
+				Aliases:     []string{"f"},
+				Usage:       "YAML config file to write to",
+				Required:    true,
+				Destination: &c.fConfigFile,
+			},
+			&cli.PathFlag{
+				Name:        "wg-dump",
+				Usage:       "output of `wg show <interface> dump` to read last handshakes from",
+				DefaultText: "only prune expired clients",
+				Destination: &c.fWgDump,
+			},
+			&cli.StringFlag{
+				Name:        "inactive-for",
+				Usage:       "prune clients whose last handshake is older than this, such as 30d or 12h; requires --wg-dump",
+				Value:       "90d",
+				Destination: &c.fInactiveFor,
+			},
+			&cli.BoolFlag{
+				Name:        "remove",
+				Usage:       "remove pruned clients from the config, instead of disabling them",
+				Destination: &c.fRemove,
+			},
+			&cli.BoolFlag{
+				Name:        "dry-run",
+				Aliases:     []string{"n"},
+				Usage:       "print what would be pruned without changing the config",
+				Destination: &c.fDryRun,
+			},
+		},
+	}
+}
+
+func (c *PruneCommand) Action(ctx *cli.Context) error {
+	cLog := getLogger(ctx)
+
+	inactiveFor, err := ParseDuration(c.fInactiveFor)
+	if err != nil {
+		cLog.Fatalf("error: %s", err.Error())
+	}
+
+	vlan, err := VLANFromFile(c.fConfigFile, cLog)
+	if err != nil {
+		cLog.Fatalf("error: %s", err.Error())
+	}
+
+	opts := PruneOptions{Now: time.Now(), InactiveFor: inactiveFor, Remove: c.fRemove}
+	if c.fWgDump != "" {
+		fp, err := os.Open(c.fWgDump)
+		if err != nil {
+			cLog.Fatalf("error: failed to open wg dump: %s", err.Error())
+		}
+		opts.Handshakes, err = ParseWgDump(fp)
+		fp.Close()
+		if err != nil {
+			cLog.Fatalf("error: failed to read wg dump (%s): %s", c.fWgDump, err.Error())
+		}
+	}
+
+	results, err := vlan.Prune(opts)
+	if err != nil {
+		cLog.Fatalf("error: %s", err.Error())
+	}
+
+	prefix := ""
+	if c.fDryRun {
+		prefix = "[dry run] "
+	}
+	for _, result := range results {
+		cLog.Printf("%s%s", prefix, result)
+	}
+	cLog.Printf("%spruned %d of %d clients", prefix, len(results), len(vlan.Clients)+countRemoved(results))
+
+	if c.fDryRun || (len(results) == 0 && !globalDryRun(ctx)) {
+		return nil
+	}
+
+	written, err := saveVLAN(ctx, vlan, c.fConfigFile, pruneChange(results))
+	if err != nil {
+		cLog.Fatalf("error: failed to write config file: %s", err.Error())
+	}
+
+	if written {
+		cLog.Printf("wrote configuration to: %s", c.fConfigFile)
+	}
+
+	return nil
+}
+
+// pruneChange describes a prune for the config's history, by the names of the pruned clients
+func pruneChange(results []PruneResult) VLANChange {
+	change := VLANChange{Command: "prune"}
+	for _, result := range results {
+		change.Args = append(change.Args, result.PeerName)
+		change.Peers = append(change.Peers, ChangedPeer{Name: result.PeerName, PublicKey: result.PublicKey})
+	}
+	return change
+}
+
+func countRemoved(results []PruneResult) int {
+	removed := 0
+	for _, result := range results {
+		if result.Removed {
+			removed++
+		}
+	}
+	return removed
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+type RedeemCommand struct {
+	fConfigFile string
+	fToken      string
+	fPublicKey  string
+}
+
+func (c *RedeemCommand) Command() *cli.Command {
+	return &cli.Command{
+		Name:        "redeem",
+		Description: "register the client an invite token was made for, and print its config without the private key",
+		Args:        false,
+		Action:      c.Action,
+		Flags: []cli.Flag{
+			&cli.PathFlag{
+				Name:        "vlan-config",
+				Aliases:     []string{"f"},
+				Usage:       "YAML config file to write to",
+				Required:    true,
+				Destination: &c.fConfigFile,
+			},
+			&cli.StringFlag{
+				Name:        "token",
+				Usage:       "invite token printed by the invite command",
+				Required:    true,
+				Destination: &c.fToken,
+			},
+			&cli.StringFlag{
+				Name:        "public-key",
+				Aliases:     []string{"pub"},
+				Usage:       "public key of the client",
+				Required:    true,
+				Destination: &c.fPublicKey,
+			},
+		},
+	}
+}
+
+func (c *RedeemCommand) Action(ctx *cli.Context) error {
+	cLog := getLogger(ctx)
+
+	vlan, err := VLANFromFile(c.fConfigFile, cLog)
+	if err != nil {
+		cLog.Fatalf("error: %s", err.Error())
+	}
+
+	client, err := vlan.RedeemInvite(c.fToken, c.fPublicKey, time.Now())
+	if err != nil {
+		cLog.Fatalf("failed to redeem invite: %s", err.Error())
+	}
+	if _, err := vlan.Validate(); err != nil {
+		cLog.Fatalf("failed to redeem invite: %s", err.Error())
+	}
+
+	iniFile, err := vlan.ClientIniTemplate(client.PeerName)
+	if err != nil {
+		cLog.Fatalf("error: %s", err.Error())
+	}
+
+	written, err := saveVLAN(ctx, vlan, c.fConfigFile, VLANChange{Command: "redeem", Args: []string{client.PeerName, client.Network}, Peers: []ChangedPeer{changedClient(client)}})
+	if err != nil {
+		cLog.Fatalf("error: failed to write config file: %s", err.Error())
+	}
+	if !written {
+		// The client config is only usable once the server knows the client
+		return nil
+	}
+	cLog.Printf("successfully created client: %s - %s", client.PeerName, client.Network)
+
+	if err := WriteIni(ctx.App.Writer, iniFile); err != nil {
+		cLog.Fatalf("error: %s", err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v2"
+)
+
+type RenumberCommand struct {
+	fConfigFile string
+	fNetwork    string
+}
+
+func (c *RenumberCommand) Command() *cli.Command {
+	return &cli.Command{
+		Name:        "renumber",
+		Description: "move the VLAN into a new subnet, keeping each address's offset where possible, and print the old and new addresses",
+		Args:        false,
+		Action:      c.Action,
+		Flags: []cli.Flag{
+			&cli.PathFlag{
+				Name:        "vlan-config",
+				Aliases:     []string{"f"},
+				Usage:       "YAML config file to write to",
+				Required:    true,
+				Destination: &c.fConfigFile,
+			},
+			&cli.StringFlag{
+				Name:        "network",
+				Usage:       "new address of the server, with the prefix length of the new subnet, such as 10.50.0.1/16",
+				Required:    true,
+				Destination: &c.fNetwork,
+			},
+		},
+	}
+}
+
+func (c *RenumberCommand) Action(ctx *cli.Context) error {
+	cLog := getLogger(ctx)
+
+	vlan, err := VLANFromFile(c.fConfigFile, cLog)
+	if err != nil {
+		cLog.Fatalf("error: %s", err.Error())
+	}
+
+	results, warnings, err := vlan.Renumber(c.fNetwork)
+	if err != nil {
+		cLog.Fatalf("failed to renumber: %s", err.Error())
+	}
+	if _, err := vlan.Validate(); err != nil {
+		cLog.Fatalf("failed to renumber: %s", err.Error())
+	}
+
+	written, err := saveVLAN(ctx, vlan, c.fConfigFile, VLANChange{Command: "renumber", Args: []string{c.fNetwork}, Peers: vlan.allPeers()})
+	if err != nil {
+		cLog.Fatalf("error: failed to write config file: %s", err.Error())
+	}
+
+	table := tabwriter.NewWriter(ctx.App.Writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "NAME\tOLD\tNEW\t")
+	for _, result := range results {
+		note := ""
+		if result.Reallocated {
+			note = "reallocated"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", result.Name, result.OldAddress, result.NewAddress, note)
+	}
+	if err := table.Flush(); err != nil {
+		cLog.Fatalf("error: %s", err.Error())
+	}
+
+	for _, warning := range warnings {
+		cLog.Printf("warning: %s", warning)
+	}
+	if written {
+		cLog.Printf("renumbered %s into %s; redistribute every exported config", c.fConfigFile, c.fNetwork)
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"github.com/urfave/cli/v2"
+)
+
+type SchemaCommand struct{}
+
+func (c *SchemaCommand) Command() *cli.Command {
+	return &cli.Command{
+		Name:        "schema",
+		Description: "print the JSON Schema of the VLAN config file",
+		Args:        false,
+		Action:      c.Action,
+	}
+}
+
+func (c *SchemaCommand) Action(ctx *cli.Context) error {
+	cLog := getLogger(ctx)
+
+	schema, err := JSONSchema()
+	if err != nil {
+		cLog.Fatalf("error generating schema: %s", err.Error())
+	}
+
+	if _, err := ctx.App.Writer.Write(schema); err != nil {
+		cLog.Fatalf("error writing schema: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+const DEFAULT_SERVE_LISTEN = "127.0.0.1:8080"
+
+type ServeCommand struct {
+	fConfigFile string
+	fListen     string
+	fToken      string
+}
+
+func (c *ServeCommand) Command() *cli.Command {
+	return &cli.Command{
+		Name:        "serve",
+		Description: "serve a REST API for managing the clients of a VLAN config file",
+		Args:        false,
+		Action:      c.Action,
+		Flags: []cli.Flag{
+			&cli.PathFlag{
+				Name:        "vlan-config",
+				Aliases:     []string{"f"},
+				Usage:       "YAML config file to manage",
+				Required:    true,
+				Destination: &c.fConfigFile,
+			},
+			&cli.StringFlag{
+				Name:        "listen",
+				Aliases:     []string{"l"},
+				Usage:       "address to listen on",
+				Value:       DEFAULT_SERVE_LISTEN,
+				Destination: &c.fListen,
+			},
+			&cli.StringFlag{
+				Name:        "token",
+				Usage:       "bearer token that API requests must carry",
+				EnvVars:     []string{API_TOKEN_ENV},
+				Required:    true,
+				Destination: &c.fToken,
+			},
+		},
+	}
+}
+
+func (c *ServeCommand) Action(ctx *cli.Context) error {
+	cLog := getLogger(ctx)
+	refuseDryRun(ctx)
+
+	// Fail early on a config that cannot be served, rather than on the first request
+	if _, err := VLANFromFile(c.fConfigFile, cLog); err != nil {
+		cLog.Fatalf("error: %s", err.Error())
+	}
+
+	server := &http.Server{
+		Addr:              c.fListen,
+		Handler:           &APIServer{ConfigFile: c.fConfigFile, Token: c.fToken, Logger: cLog, Recorder: globalRecorder(ctx)},
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	cLog.Printf("serving API for %s on http://%s%s", c.fConfigFile, c.fListen, API_PREFIX)
+	if err := server.ListenAndServe(); err != nil {
+		cLog.Fatalf("error: %s", err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
+)
+
+type TUICommand struct {
+	fConfigFile string
+}
+
+func (c *TUICommand) Command() *cli.Command {
+	return &cli.Command{
+		Name:        "tui",
+		Description: "browse clients and their configs in a full-screen terminal interface, and add or delete clients",
+		Args:        false,
+		Action:      c.Action,
+		Flags: []cli.Flag{
+			&cli.PathFlag{
+				Name:        "vlan-config",
+				Aliases:     []string{"f"},
+				Usage:       "YAML config file to manage",
+				Required:    true,
+				Destination: &c.fConfigFile,
+			},
+		},
+	}
+}
+
+func (c *TUICommand) Action(ctx *cli.Context) error {
+	cLog := getLogger(ctx)
+	refuseDryRun(ctx)
+
+	// Warnings are logged before the screen is taken over, since they would be drawn over afterwards
+	vlan, err := VLANFromFile(c.fConfigFile, cLog)
+	if err != nil {
+		cLog.Fatalf("error: %s", err.Error())
+	}
+
+	stdin, stdout := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(stdin) || !term.IsTerminal(stdout) {
+		cLog.Fatalf("error: tui needs an interactive terminal")
+	}
+	state, err := term.MakeRaw(stdin)
+	if err != nil {
+		cLog.Fatalf("error: %s", err.Error())
+	}
+
+	// Switch to the alternate screen and hide the cursor, and restore both on the way out
+	fmt.Fprint(os.Stdout, "\x1b[?1049h\x1b[?25l")
+	model := &tuiModel{ConfigFile: c.fConfigFile, VLAN: vlan, Recorder: globalRecorder(ctx)}
+	err = runTUI(os.Stdin, os.Stdout, model, func() (int, int) {
+		width, height, err := term.GetSize(stdout)
+		if err != nil {
+			return TUI_DEFAULT_WIDTH, TUI_DEFAULT_HEIGHT
+		}
+		return width, height
+	})
+	fmt.Fprint(os.Stdout, "\x1b[?25h\x1b[?1049l")
+	term.Restore(stdin, state)
+
+	if err != nil {
+		cLog.Fatalf("error: %s", err.Error())
+	}
+	if model.message != "" {
+		cLog.Print(model.message)
+	}
+
+	return nil
+}
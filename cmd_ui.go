@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+const DEFAULT_UI_LISTEN = "127.0.0.1:8081"
+
+type UICommand struct {
+	fConfigFile string
+	fListen     string
+	fPassword   string
+	fHosts      cli.StringSlice
+}
+
+func (c *UICommand) Command() *cli.Command {
+	return &cli.Command{
+		Name:        "ui",
+		Description: "serve a web UI for listing peers, adding clients with a QR code for their config, and disabling peers",
+		Args:        false,
+		Action:      c.Action,
+		Flags: []cli.Flag{
+			&cli.PathFlag{
+				Name:        "vlan-config",
+				Aliases:     []string{"f"},
+				Usage:       "YAML config file to manage",
+				Required:    true,
+				Destination: &c.fConfigFile,
+			},
+			&cli.StringFlag{
+				Name:        "listen",
+				Aliases:     []string{"l"},
+				Usage:       "address to listen on",
+				Value:       DEFAULT_UI_LISTEN,
+				Destination: &c.fListen,
+			},
+			&cli.StringFlag{
+				Name:        "password",
+				Usage:       "password to require with HTTP basic authentication",
+				EnvVars:     []string{UI_PASSWORD_ENV},
+				DefaultText: "generated and printed at startup",
+				Destination: &c.fPassword,
+			},
+			&cli.StringSliceFlag{
+				Name:        "host",
+				Usage:       "host name the UI is reached by, such as that of a proxy in front of it, besides localhost and the listen address; may be repeated",
+				Destination: &c.fHosts,
+			},
+		},
+	}
+}
+
+func (c *UICommand) Action(ctx *cli.Context) error {
+	cLog := getLogger(ctx)
+	refuseDryRun(ctx)
+
+	if _, err := VLANFromFile(c.fConfigFile, cLog); err != nil {
+		cLog.Fatalf("error: %s", err.Error())
+	}
+
+	listenHost, _, err := net.SplitHostPort(c.fListen)
+	if err != nil {
+		cLog.Fatalf("error: invalid listen address '%s': %s", c.fListen, err.Error())
+	}
+	if listenHost == "" {
+		// Listening on every address, as for ":8081"
+		listenHost = net.IPv6unspecified.String()
+	}
+
+	// The UI shows every client's private key, so it is never served without a password
+	password := c.fPassword
+	if password == "" {
+		if password, err = newUIPassword(); err != nil {
+			cLog.Fatalf("error: failed generating a password: %s", err.Error())
+		}
+		cLog.Printf("no --password set; log in with this password, under any user name: %s", password)
+	}
+
+	ui, err := NewUIServer(c.fConfigFile, password, cLog)
+	if err != nil {
+		cLog.Fatalf("error: %s", err.Error())
+	}
+	ui.Recorder = globalRecorder(ctx)
+	ui.Hosts = append([]string{listenHost}, c.fHosts.Value()...)
+
+	server := &http.Server{
+		Addr:              c.fListen,
+		Handler:           ui,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	cLog.Printf("serving UI for %s on http://%s/", c.fConfigFile, c.fListen)
+	if err := server.ListenAndServe(); err != nil {
+		cLog.Fatalf("error: %s", err.Error())
+	}
+
+	return nil
+}
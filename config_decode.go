@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configSource locates the values of a decoded config in its YAML document, for pointing findings at their position
+// in the file. A nil source, as for configs built in memory, knows no positions.
+type configSource struct {
+	document *yaml.Node
+	root     *yaml.Node
+}
+
+// node finds the node at a path of mapping keys (strings) and sequence indexes (ints), or nil if there is none
+func (src *configSource) node(path ...interface{}) *yaml.Node {
+	if src == nil {
+		return nil
+	}
+	node := src.root
+	for _, step := range path {
+		node = resolveAlias(node)
+		var next *yaml.Node
+		switch step := step.(type) {
+		case string:
+			if node.Kind == yaml.MappingNode {
+				for idx := 0; idx+1 < len(node.Content); idx += 2 {
+					if node.Content[idx].Value == step {
+						next = node.Content[idx+1]
+					}
+				}
+			}
+		case int:
+			if node.Kind == yaml.SequenceNode && step < len(node.Content) {
+				next = node.Content[step]
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
+
+// position describes where the node at a path is in the file, or returns "" if it is unknown
+func (src *configSource) position(path ...interface{}) string {
+	node := src.node(path...)
+	if node == nil || node.Line == 0 {
+		// Nodes added by migrations have no position
+		return ""
+	}
+	return nodePosition(node)
+}
+
+func nodePosition(node *yaml.Node) string {
+	return fmt.Sprintf("line %d, column %d", node.Line, node.Column)
+}
+
+// resolveAlias follows an alias node to the node it refers to
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// decodeConfigDocument strictly decodes a config document into a VLAN: unknown keys and values of the wrong type are
+// all reported with their position, instead of being ignored or failing on the first one. data is the text the
+// document was parsed from, or nil if migrations changed it, in which case positions refer to the migrated text.
+func decodeConfigDocument(document *yaml.Node, data []byte) (*VLAN, error) {
+	positions := document
+	if data == nil {
+		var err error
+		if data, err = encodeConfigDocument(document); err != nil {
+			return nil, err
+		}
+		if positions, _, err = parseConfigDocument(data); err != nil {
+			return nil, err
+		}
+	}
+
+	vlan := &VLAN{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(vlan); err != nil && !errors.Is(err, io.EOF) {
+		return nil, positionedDecodeError(err, positions)
+	}
+	vlan.source = &configSource{document: document, root: document.Content[0]}
+	return vlan, nil
+}
+
+// positionedDecodeError splits the findings of a decoding error into one error each. yaml.v3 only reports their
+// line, so the column of the entry starting that line is added.
+func positionedDecodeError(err error, document *yaml.Node) error {
+	var typeError *yaml.TypeError
+	if !errors.As(err, &typeError) {
+		return err
+	}
+	errs := []error{}
+	for _, finding := range typeError.Errors {
+		var line int
+		if _, scanErr := fmt.Sscanf(finding, "line %d:", &line); scanErr == nil {
+			if node := firstNodeOnLine(document, line); node != nil {
+				_, message, _ := strings.Cut(finding, ":")
+				finding = fmt.Sprintf("%s:%s", nodePosition(node), message)
+			}
+		}
+		errs = append(errs, errors.New(finding))
+	}
+	return errors.Join(errs...)
+}
+
+// firstNodeOnLine finds the first node of a document, in document order, that starts on the given line
+func firstNodeOnLine(node *yaml.Node, line int) *yaml.Node {
+	if node.Line == line && node.Kind != yaml.DocumentNode {
+		return node
+	}
+	for _, child := range node.Content {
+		if found := firstNodeOnLine(child, line); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// marshalYAML encodes a value as YAML with two-space indentation
+func marshalYAML(value interface{}) ([]byte, error) {
+	buf := bytes.Buffer{}
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(value); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
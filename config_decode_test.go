@@ -0,0 +1,145 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const strictTestServer = `version: 1
+public_endpoint: vpn.example.com:51820
+server:
+  peer_name: wg-vlan
+  listen_port: 51820
+  network: 10.20.30.1/24
+  private_key: kpAD+MlqhRQT/65EfSc4sNWPXLvTV8v0uBVL+wEvlCk=
+`
+
+func writeTestConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "vlan.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+	return path
+}
+
+// copyTestConfig copies a config from testdata into a temporary file, for tests that change it
+func copyTestConfig(t *testing.T, name string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to read test config: %v", err)
+	}
+	return writeTestConfig(t, string(content))
+}
+
+func TestVLANFromFileStrict(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		input    string
+		errParts []string
+	}{
+		{
+			"unknown server field",
+			strings.Replace(strictTestServer, "listen_port", "listen-port", 1),
+			[]string{"line 5, column 3: field listen-port not found in type main.VLANServer"},
+		},
+		{
+			"unknown client field",
+			strictTestServer + "clients:\n- peer_name: alice\n  network: 10.20.30.2\n  public_key: ZK3+qu5D9HjC+GRRym7beOaIQDyHzfQnmnhS6o2653o=\n  presharedkey: abc\n",
+			[]string{"line 12, column 3: field presharedkey not found in type main.VLANClient"},
+		},
+		{
+			"unknown top-level field",
+			strictTestServer + "keepalive_interval: 25\n",
+			[]string{"line 8, column 1: field keepalive_interval not found in type main.VLAN"},
+		},
+		{
+			"wrong scalar type",
+			strings.Replace(strictTestServer, "51820\n  network", "fast\n  network", 1),
+			[]string{"line 5, column 3: cannot unmarshal !!str `fast` into uint"},
+		},
+		{
+			"wrong collection type",
+			strictTestServer + "clients:\n  peer_name: alice\n",
+			[]string{"line 9, column 3: cannot unmarshal !!map into []*main.VLANClient"},
+		},
+		{
+			"invalid extra",
+			strings.Replace(strictTestServer, "  peer_name", "  extra:\n    MTU: {nested: 1}\n  peer_name", 1),
+			[]string{"line 5: extra values must be a scalar or a list of scalars"},
+		},
+		{
+			"all errors reported",
+			strings.Replace(strictTestServer, "peer_name", "name", 1) + "keepalive: 25\n",
+			[]string{"line 4, column 3: field name not found in type main.VLANServer", "line 8, column 1: field keepalive not found in type main.VLAN"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := VLANFromFile(writeTestConfig(t, tc.input), nil)
+			if err == nil {
+				t.Fatalf("expected error")
+			}
+			for _, part := range tc.errParts {
+				if !strings.Contains(err.Error(), part) {
+					t.Errorf("expected error containing %q, got: %v", part, err)
+				}
+			}
+		})
+	}
+}
+
+// The config moved from go-yaml v2 to yaml.v3, which differ in how some scalars resolve and in repeated keys
+func TestVLANFromFileYAMLCompat(t *testing.T) {
+	input := strings.Replace(strictTestServer, "server:", "keep_alive: 025\nserver:", 1) +
+		"  hooks: &hooks\n    post_up: [logger up]\n" +
+		"clients:\n- peer_name: alice\n  network: 10.20.30.2\n  public_key: ZK3+qu5D9HjC+GRRym7beOaIQDyHzfQnmnhS6o2653o=\n" +
+		"  preshared_key: ibAKWyEwwqZsA8c/dbDHdPijC0nzT4aQR/VTEVoQLXM=\n  full_tunnel: yes\n" +
+		"  hooks:\n    <<: *hooks\n    pre_up: [logger pre]\n"
+
+	vlan, err := VLANFromFile(writeTestConfig(t, input), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vlan.KeepAlive != 21 {
+		t.Errorf("expected octal keep_alive 025 to be 21, got %d", vlan.KeepAlive)
+	}
+	alice := vlan.Client("alice")
+	if !alice.FullTunnel {
+		t.Errorf("expected full_tunnel: yes to be true")
+	}
+	if !slices.Equal(alice.Hooks.PostUp, []string{"logger up"}) || !slices.Equal(alice.Hooks.PreUp, []string{"logger pre"}) {
+		t.Errorf("expected merged hooks, got %+v", alice.Hooks)
+	}
+
+	_, err = VLANFromFile(writeTestConfig(t, strictTestServer+"keep_alive: 25\nkeep_alive: 30\n"), nil)
+	if err == nil || !strings.Contains(err.Error(), `line 9, column 1: mapping key "keep_alive" already defined at line 8`) {
+		t.Errorf("expected a repeated key error, got %v", err)
+	}
+}
+
+func TestValidatePositions(t *testing.T) {
+	input := strictTestServer + "clients:\n- peer_name: alice\n  network: 10.20.30.2\n  public_key: ZK3+qu5D9HjC+GRRym7beOaIQDyHzfQnmnhS6o2653o=\n"
+
+	logged := strings.Builder{}
+	if _, err := VLANFromFile(writeTestConfig(t, input), log.New(&logged, "", 0)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "config warning: client[0] (line 9, column 3): client preshared key unset; this is unsafe"
+	if !strings.Contains(logged.String(), expected) {
+		t.Errorf("expected warning %q, got:\n%s", expected, logged.String())
+	}
+
+	// Configs built in memory have no positions to report
+	vlan := loadTestVLAN(t, "basic.yaml")
+	vlan.source = nil
+	vlan.Client("phone").PresharedKey = ""
+	warnings, _ := vlan.Validate()
+	if !slices.Contains(warnings, "client[2]: client preshared key unset; this is unsafe") {
+		t.Errorf("expected warning without position, got %v", warnings)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CONFIG_IDENTITY_KEY identifies the items of a list of peers, so that edits follow peers rather than list positions
+const CONFIG_IDENTITY_KEY = "peer_name"
+
+// mergeYAMLNode updates dst in place to hold the data of src, touching only the nodes whose data changed. Comments,
+// key order, and the original text and quoting of unchanged values in dst survive.
+func mergeYAMLNode(dst *yaml.Node, src *yaml.Node) {
+	dst = resolveAlias(dst)
+	src = resolveAlias(src)
+
+	switch {
+	case dst.Kind == yaml.MappingNode && src.Kind == yaml.MappingNode:
+		mergeYAMLMapping(dst, src)
+	case dst.Kind == yaml.SequenceNode && src.Kind == yaml.SequenceNode:
+		mergeYAMLSequence(dst, src)
+	case dst.Kind == yaml.ScalarNode && src.Kind == yaml.ScalarNode:
+		if dst.Value != src.Value && !equalYAMLTimestamps(dst, src) {
+			dst.Value = src.Value
+			dst.Tag = src.Tag
+			dst.Style = src.Style
+		}
+	default:
+		replaceYAMLNode(dst, src)
+	}
+}
+
+func mergeYAMLMapping(dst *yaml.Node, src *yaml.Node) {
+	content := []*yaml.Node{}
+	srcValues := map[string]*yaml.Node{}
+	for idx := 0; idx+1 < len(src.Content); idx += 2 {
+		srcValues[src.Content[idx].Value] = src.Content[idx+1]
+	}
+
+	// Existing keys keep their place; keys the new data lacks are dropped, unless they only spell out a zero value
+	// that was left out of the new data as empty
+	merged := map[string]bool{}
+	for idx := 0; idx+1 < len(dst.Content); idx += 2 {
+		key, value := dst.Content[idx], dst.Content[idx+1]
+		if srcValue, ok := srcValues[key.Value]; ok {
+			mergeYAMLNode(value, srcValue)
+		} else if !isZeroYAMLNode(value) {
+			continue
+		}
+		merged[key.Value] = true
+		content = append(content, key, value)
+	}
+	for idx := 0; idx+1 < len(src.Content); idx += 2 {
+		if !merged[src.Content[idx].Value] {
+			content = append(content, src.Content[idx], src.Content[idx+1])
+		}
+	}
+	dst.Content = content
+}
+
+func mergeYAMLSequence(dst *yaml.Node, src *yaml.Node) {
+	// Lists of peers are matched by name, so that adding or removing one leaves the comments of the others in place
+	dstByName := map[string]*yaml.Node{}
+	for _, item := range dst.Content {
+		if name := yamlIdentity(item); name != "" {
+			dstByName[name] = item
+		}
+	}
+
+	content := []*yaml.Node{}
+	for idx, srcItem := range src.Content {
+		var dstItem *yaml.Node
+		if name := yamlIdentity(srcItem); name != "" {
+			dstItem = dstByName[name]
+		} else if idx < len(dst.Content) && yamlIdentity(dst.Content[idx]) == "" {
+			dstItem = dst.Content[idx]
+		}
+		if dstItem == nil {
+			content = append(content, srcItem)
+			continue
+		}
+		mergeYAMLNode(dstItem, srcItem)
+		content = append(content, dstItem)
+	}
+	dst.Content = content
+}
+
+// equalYAMLTimestamps reports whether two scalars are the same point in time, however they are written; a date as
+// written by hand is encoded back as a full time
+func equalYAMLTimestamps(first *yaml.Node, second *yaml.Node) bool {
+	if first.ShortTag() != "!!timestamp" || second.ShortTag() != "!!timestamp" {
+		return false
+	}
+	var firstTime, secondTime time.Time
+	if first.Decode(&firstTime) != nil || second.Decode(&secondTime) != nil {
+		return false
+	}
+	return firstTime.Equal(secondTime)
+}
+
+// yamlIdentity returns the peer name of a list item, or "" if it is not a peer
+func yamlIdentity(node *yaml.Node) string {
+	node = resolveAlias(node)
+	if node.Kind != yaml.MappingNode {
+		return ""
+	}
+	for idx := 0; idx+1 < len(node.Content); idx += 2 {
+		if node.Content[idx].Value == CONFIG_IDENTITY_KEY {
+			return node.Content[idx+1].Value
+		}
+	}
+	return ""
+}
+
+// replaceYAMLNode swaps the data of dst for that of src, keeping the comments attached to dst
+func replaceYAMLNode(dst *yaml.Node, src *yaml.Node) {
+	headComment, lineComment, footComment := dst.HeadComment, dst.LineComment, dst.FootComment
+	*dst = *src
+	dst.HeadComment, dst.LineComment, dst.FootComment = headComment, lineComment, footComment
+}
+
+// isZeroYAMLNode reports whether a node holds a value that encodes as empty: null, false, zero, an empty string, or an
+// empty collection
+func isZeroYAMLNode(node *yaml.Node) bool {
+	node = resolveAlias(node)
+	switch node.Kind {
+	case yaml.MappingNode, yaml.SequenceNode:
+		return len(node.Content) == 0
+	case yaml.ScalarNode:
+		switch node.ShortTag() {
+		case "!!null":
+			return true
+		case "!!bool":
+			var value bool
+			return node.Decode(&value) == nil && !value
+		case "!!int":
+			var value int64
+			return node.Decode(&value) == nil && value == 0
+		case "!!str":
+			return node.Value == ""
+		}
+	}
+	return false
+}
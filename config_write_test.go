@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteToPreservesComments(t *testing.T) {
+	vlan := loadTestVLAN(t, "commented.yaml")
+
+	// Add a client, remove another, and rotate a key
+	vlan.Clients = append(vlan.Clients, &VLANClient{
+		PeerName:     "dave",
+		Network:      "10.20.30.5",
+		PublicKey:    "LnIaiVPVBzkWAW0bU+Csq15/9Kd+890dJzUB5LLpMU0=",
+		PresharedKey: "ibAKWyEwwqZsA8c/dbDHdPijC0nzT4aQR/VTEVoQLXM=",
+	})
+	remaining := []*VLANClient{}
+	for _, client := range vlan.Clients {
+		if client.PeerName != "bob" {
+			remaining = append(remaining, client)
+		}
+	}
+	vlan.Clients = remaining
+	vlan.Client("alice").PresharedKey = "CyAALAHGcYL0OP6RRiFb1SVAaH/LLrfPenK5+2jCMEU="
+
+	path := filepath.Join(t.TempDir(), "vlan.yaml")
+	if err := vlan.WriteTo(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertGolden(t, "commented.written.yaml", string(written))
+
+	reread, err := VLANFromFile(path, nil)
+	if err != nil {
+		t.Fatalf("written config failed to load: %v", err)
+	}
+	expected, _ := vlan.JSON(false)
+	actual, _ := reread.JSON(false)
+	if string(expected) != string(actual) {
+		t.Errorf("written config does not match the VLAN\n--- expected ---\n%s\n--- actual ---\n%s", expected, actual)
+	}
+}
+
+func TestWriteToUnchanged(t *testing.T) {
+	vlan := loadTestVLAN(t, "commented.yaml")
+	path := filepath.Join(t.TempDir(), "vlan.yaml")
+	if err := vlan.WriteTo(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Beyond the added version key and normalized indentation, an unchanged VLAN is written as it was read
+	assertGolden(t, "commented.unchanged.yaml", string(written))
+}
+
+func TestWriteToReplacesAtomically(t *testing.T) {
+	dir := t.TempDir()
+	vlan := loadTestVLAN(t, "basic.yaml")
+
+	// A new config holds private keys, so only its owner may read it
+	path := filepath.Join(dir, "vlan.yaml")
+	if err := vlan.WriteTo(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected a new config to have mode 0600, got %v", info.Mode())
+	}
+
+	// An existing config keeps its mode, and is replaced rather than truncated and written over
+	if err := os.Chmod(path, 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(path, filepath.Join(dir, "old.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	old, _ := os.ReadFile(path)
+	vlan.Client("alice").Disabled = true
+	if err := vlan.WriteTo(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("expected the config to keep mode 0640, got %v", info.Mode())
+	}
+	if linked, _ := os.ReadFile(filepath.Join(dir, "old.yaml")); string(linked) != string(old) {
+		t.Errorf("expected the old file to be left whole")
+	}
+
+	// A symlinked config stays a symlink to the replaced file
+	link := filepath.Join(dir, "link.yaml")
+	if err := os.Symlink(path, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := vlan.WriteTo(link); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("expected the symlink to be kept")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 3 {
+		t.Errorf("expected no temporary files to be left behind, got %v", entries)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// DIFF_CONTEXT is the number of unchanged lines shown around changes in unified diffs
+const DIFF_CONTEXT = 3
+
+type diffOp struct {
+	kind byte
+	line string
+	// oldPos and newPos are the indexes of the line in the old and new texts, or where it would be
+	oldPos int
+	newPos int
+}
+
+// UnifiedDiff renders the line differences between two texts in unified diff format. It returns an empty string
+// when the texts are equal.
+func UnifiedDiff(oldName string, newName string, oldText string, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+	ops := diffLines(diffSplitLines(oldText), diffSplitLines(newText))
+
+	changes := []int{}
+	for idx, op := range ops {
+		if op.kind != ' ' {
+			changes = append(changes, idx)
+		}
+	}
+
+	buf := strings.Builder{}
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", oldName, newName)
+	for i := 0; i < len(changes); {
+		start := changes[i] - DIFF_CONTEXT
+		if start < 0 {
+			start = 0
+		}
+		end := diffHunkEnd(changes[i], len(ops))
+		// Changes whose context touches the hunk's join it
+		for i++; i < len(changes) && changes[i]-DIFF_CONTEXT <= end; i++ {
+			end = diffHunkEnd(changes[i], len(ops))
+		}
+		diffWriteHunk(&buf, ops[start:end])
+	}
+	return buf.String()
+}
+
+// diffLabel names a file in a diff header the way git does, under an "a/" or "b/" prefix
+func diffLabel(prefix string, path string) string {
+	return prefix + strings.TrimPrefix(filepath.ToSlash(path), "/")
+}
+
+func diffHunkEnd(change int, length int) int {
+	if end := change + DIFF_CONTEXT + 1; end < length {
+		return end
+	}
+	return length
+}
+
+func diffWriteHunk(buf *strings.Builder, hunk []diffOp) {
+	oldCount, newCount := 0, 0
+	for _, op := range hunk {
+		if op.kind != '+' {
+			oldCount++
+		}
+		if op.kind != '-' {
+			newCount++
+		}
+	}
+	// Empty ranges start at the line before them
+	oldStart, newStart := hunk[0].oldPos, hunk[0].newPos
+	if oldCount > 0 {
+		oldStart++
+	}
+	if newCount > 0 {
+		newStart++
+	}
+
+	fmt.Fprintf(buf, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+	for _, op := range hunk {
+		fmt.Fprintf(buf, "%c%s\n", op.kind, op.line)
+	}
+}
+
+// diffLines finds a shortest edit script between two lists of lines, from their longest common subsequence
+func diffLines(oldLines []string, newLines []string) []diffOp {
+	lcs := make([][]int, len(oldLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	ops := []diffOp{}
+	i, j := 0, 0
+	for i < len(oldLines) || j < len(newLines) {
+		switch {
+		case i < len(oldLines) && j < len(newLines) && oldLines[i] == newLines[j]:
+			ops = append(ops, diffOp{' ', oldLines[i], i, j})
+			i++
+			j++
+		case j == len(newLines) || (i < len(oldLines) && lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', oldLines[i], i, j})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', newLines[j], i, j})
+			j++
+		}
+	}
+	return ops
+}
+
+func diffSplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		old      string
+		new      string
+		expected string
+	}{
+		{
+			name:     "equal",
+			old:      "a\nb\n",
+			new:      "a\nb\n",
+			expected: "",
+		},
+		{
+			name: "created",
+			old:  "",
+			new:  "a\nb\n",
+			expected: "--- old\n+++ new\n" +
+				"@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "changed line",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n",
+			new:  "1\n2\n3\n4\nfive\n6\n7\n8\n",
+			expected: "--- old\n+++ new\n" +
+				"@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "separate hunks",
+			old:  "a\n1\n2\n3\n4\n5\n6\n7\nb\n",
+			new:  "A\n1\n2\n3\n4\n5\n6\n7\nB\n",
+			expected: "--- old\n+++ new\n" +
+				"@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n" +
+				"@@ -6,4 +6,4 @@\n 5\n 6\n 7\n-b\n+B\n",
+		},
+		{
+			name: "close changes share a hunk",
+			old:  "a\n1\n2\nb\n",
+			new:  "1\n2\n",
+			expected: "--- old\n+++ new\n" +
+				"@@ -1,4 +1,2 @@\n-a\n 1\n 2\n-b\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := UnifiedDiff("old", "new", tc.old, tc.new); actual != tc.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestVLANDiff(t *testing.T) {
+	// Written once, so that the file is formatted the way it will be written again
+	path := filepath.Join(t.TempDir(), "vlan.yaml")
+	if err := loadTestVLAN(t, "basic.yaml").WriteTo(path); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read test config: %v", err)
+	}
+
+	vlan, err := VLANFromFile(path, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	unchanged, err := VLANDiff(vlan, path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if unchanged != "" {
+		t.Errorf("expected no diff for an unchanged config, got:\n%s", unchanged)
+	}
+
+	if _, err := vlan.NewClient("carol", ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	diff, err := VLANDiff(vlan, path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, part := range []string{diffLabel("--- a/", path), "+  - peer_name: carol", "--- a/wg0.conf", "+# VLAN Client: carol", "+AllowedIPs"} {
+		if !strings.Contains(diff, part) {
+			t.Errorf("expected diff to contain %q, got:\n%s", part, diff)
+		}
+	}
+	if written, _ := os.ReadFile(path); string(written) != string(content) {
+		t.Errorf("expected the config file to be left untouched")
+	}
+}
+
+func TestVLANDiffNewFile(t *testing.T) {
+	vlan := loadTestVLAN(t, "basic.yaml")
+	diff, err := VLANDiff(vlan, filepath.Join(t.TempDir(), "new.yaml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(diff, "--- /dev/null\n") || !strings.Contains(diff, "--- /dev/null\n+++ b/wg0.conf\n") {
+		t.Errorf("expected diffs against nothing, got:\n%s", diff)
+	}
+}
+
+func TestDryRunPrintsReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vlan.yaml")
+	if err := loadTestVLAN(t, "basic.yaml").WriteTo(path); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+
+	renumberCommand := RenumberCommand{}
+	out, logged := strings.Builder{}, strings.Builder{}
+	app := &cli.App{
+		Flags:     []cli.Flag{&cli.BoolFlag{Name: DRY_RUN_FLAG}},
+		Commands:  []*cli.Command{renumberCommand.Command()},
+		Writer:    &out,
+		ErrWriter: &logged,
+	}
+	if err := app.Run([]string{"wg-vlan", "--dry-run", "renumber", "-f", path, "--network", "10.50.0.1/16"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The diff is followed by the command's own report, which a dry run must not cut off
+	for _, part := range []string{"+  network: 10.50.0.1/16", "NAME", "alice    10.20.30.2     10.50.0.2"} {
+		if !strings.Contains(out.String(), part) {
+			t.Errorf("expected %q in output:\n%s", part, out.String())
+		}
+	}
+	if strings.Contains(logged.String(), "renumbered") {
+		t.Errorf("expected no claim of renumbering the config, got:\n%s", logged.String())
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(before) {
+		t.Errorf("expected the config to be left alone, got:\n%s", after)
+	}
+}
+
+func TestVLANSaver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vlan.yaml")
+	vlan := loadTestVLAN(t, "basic.yaml")
+	if err := vlan.WriteTo(path); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	auditLog := filepath.Join(t.TempDir(), "audit.jsonl")
+	change := VLANChange{Command: "client-disable", Args: []string{"alice"}, Peers: []ChangedPeer{changedClient(vlan.Client("alice"))}}
+	vlan.Client("alice").Disabled = true
+
+	saver := VLANSaver{DryRun: true, Recorder: ChangeRecorder{AuditLog: auditLog}}
+	written, diff, err := saver.Save(vlan, path, change)
+	if err != nil || written {
+		t.Fatalf("expected a dry run to write nothing, got %v, %v", written, err)
+	}
+	if !strings.Contains(diff, "+    disabled: true") {
+		t.Errorf("expected the diff to show the change, got:\n%s", diff)
+	}
+	if _, err := os.Stat(auditLog); !os.IsNotExist(err) {
+		t.Errorf("expected a dry run to record nothing")
+	}
+
+	saver.DryRun = false
+	written, diff, err = saver.Save(vlan, path, change)
+	if err != nil || !written || diff != "" {
+		t.Fatalf("expected the config to be written, got %v, %q, %v", written, diff, err)
+	}
+	if saved, err := VLANFromFile(path, nil); err != nil || !saved.Client("alice").Disabled {
+		t.Errorf("expected the change to be saved")
+	}
+	if content, _ := os.ReadFile(auditLog); !strings.Contains(string(content), `"command":"client-disable"`) {
+		t.Errorf("expected the change to be recorded, got: %s", content)
+	}
+}
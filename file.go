@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// CONFIG_LOCK_SUFFIX names the file next to a config that writers lock; see lockConfigFile
+const CONFIG_LOCK_SUFFIX = ".lock"
+
+// writeFileAtomic replaces the file at path with data. The data goes to a temporary file in the same directory, created
+// with perm before anything is written to it, which is then renamed over path. Readers see the old content or the new
+// one in full, never a mix or a truncated file, and secrets are never readable with the old file's laxer mode.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	fp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := fp.Name()
+	if err := writeTempFile(fp, data, perm); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// writeTempFile sets the mode of a new temporary file, which is created as 0600, writes data to it, and closes it
+func writeTempFile(fp *os.File, data []byte, perm os.FileMode) error {
+	if err := fp.Chmod(perm); err != nil {
+		fp.Close()
+		return err
+	}
+	if _, err := fp.Write(data); err != nil {
+		fp.Close()
+		return err
+	}
+	if err := fp.Sync(); err != nil {
+		fp.Close()
+		return err
+	}
+	return fp.Close()
+}
+
+// lockConfigFile takes an exclusive lock on a config file, held until the returned file is closed, so that processes
+// changing the same config take turns. The lock is on a file next to the config, since writing replaces the config's
+// own file.
+func lockConfigFile(path string) (*os.File, error) {
+	fp, err := os.OpenFile(resolveConfigPath(path)+CONFIG_LOCK_SUFFIX, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock config file (%s): %w", path, err)
+	}
+	if err := lockFile(fp); err != nil {
+		fp.Close()
+		return nil, fmt.Errorf("failed to lock config file (%s): %w", path, err)
+	}
+	return fp, nil
+}
+
+// resolveConfigPath follows symlinks to the config file, so that replacing it replaces the file linked to rather than
+// the link. A path that does not exist yet is used as it is.
+func resolveConfigPath(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return path
+}
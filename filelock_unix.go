@@ -0,0 +1,15 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockFile takes an exclusive lock on an open file, waiting while another process holds it. The lock is advisory, and
+// is released when the file is closed.
+func lockFile(fp *os.File) error {
+	return unix.Flock(int(fp.Fd()), unix.LOCK_EX)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata with the current output")
+
+func loadTestVLAN(t *testing.T, name string) *VLAN {
+	t.Helper()
+	vlan, err := VLANFromFile(filepath.Join("testdata", name), nil)
+	if err != nil {
+		t.Fatalf("failed to load test VLAN %s: %v", name, err)
+	}
+	return vlan
+}
+
+func assertGolden(t *testing.T, name string, actual string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+
+	if *updateGolden {
+		if err := os.WriteFile(path, []byte(actual), 0644); err != nil {
+			t.Fatalf("failed to update golden file %s: %v", path, err)
+		}
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file %s: %v", path, err)
+	}
+	if string(expected) != actual {
+		t.Errorf("output does not match golden file %s\n--- expected ---\n%s\n--- actual ---\n%s", path, expected, actual)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// GIT_HISTORY_FLAG is the global flag that makes mutating commands commit the config file into git after writing it
+const GIT_HISTORY_FLAG = "git"
+const GIT_HISTORY_ENV = "WG_VLAN_GIT"
+
+// HistoryEntry is one commit changing the config file
+type HistoryEntry struct {
+	Hash    string
+	Author  string
+	When    time.Time
+	Message string
+}
+
+// CommitConfigChange commits the config file at path into the git repository holding it, initializing a repository
+// in the file's directory if there is none. The message describes the change, such as "client-add alice 10.20.30.4".
+// It refuses to commit while other changes are staged, which would otherwise be committed along with it. It returns
+// the new commit's hash, or the zero hash if the file did not change. The first commit of a config holding private
+// keys logs a warning to warningLogger, since the keys stay in the repository's history from then on.
+func CommitConfigChange(path string, message string, now time.Time, warningLogger *log.Logger) (plumbing.Hash, error) {
+	repo, relPath, err := openHistoryRepo(path)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		if _, err := git.PlainInit(filepath.Dir(path), false); err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("failed to initialize git repository: %w", err)
+		}
+		repo, relPath, err = openHistoryRepo(path)
+	}
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	staged := []string{}
+	for stagedPath, fileStatus := range status {
+		if stagedPath != relPath && fileStatus.Staging != git.Unmodified && fileStatus.Staging != git.Untracked {
+			staged = append(staged, stagedPath)
+		}
+	}
+	if len(staged) > 0 {
+		sort.Strings(staged)
+		return plumbing.ZeroHash, fmt.Errorf("refusing to commit %s while other changes are staged in %s (%s); commit or unstage them first", relPath, worktree.Filesystem.Root(), strings.Join(staged, ", "))
+	}
+
+	if _, err := worktree.Add(relPath); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to stage %s: %w", relPath, err)
+	}
+	if status, err = worktree.Status(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	// Files matching HEAD are left out of the status
+	fileStatus, changed := status[relPath]
+	if !changed {
+		return plumbing.ZeroHash, nil
+	}
+	if fileStatus.Staging == git.Added && warningLogger != nil && configHoldsPrivateKeys(path) {
+		warningLogger.Printf("warning: %s holds private keys, which are now in the git history of %s; anyone who can read the repository can read them, even after they are removed from the config", path, worktree.Filesystem.Root())
+	}
+
+	hash, err := worktree.Commit(message, &git.CommitOptions{Author: historySignature(repo, now)})
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to commit %s: %w", relPath, err)
+	}
+	return hash, nil
+}
+
+// ConfigHistory lists the commits changing the config file at path, newest first. A limit above zero stops the list
+// after that many entries.
+func ConfigHistory(path string, limit int) ([]HistoryEntry, error) {
+	repo, relPath, err := openHistoryRepo(path)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		return nil, fmt.Errorf("no git repository holds %s; changes are committed with --%s", path, GIT_HISTORY_FLAG)
+	} else if err != nil {
+		return nil, err
+	}
+
+	head, err := repo.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return []HistoryEntry{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	commits, err := repo.Log(&git.LogOptions{From: head.Hash(), FileName: &relPath})
+	if err != nil {
+		return nil, err
+	}
+	defer commits.Close()
+
+	entries := []HistoryEntry{}
+	err = commits.ForEach(func(commit *object.Commit) error {
+		entries = append(entries, HistoryEntry{
+			Hash:    commit.Hash.String(),
+			Author:  commit.Author.Name,
+			When:    commit.Author.When,
+			Message: strings.TrimSpace(commit.Message),
+		})
+		if limit > 0 && len(entries) >= limit {
+			return storer.ErrStop
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// configHoldsPrivateKeys reports whether the config at path holds the server's or any client's private key
+func configHoldsPrivateKeys(path string) bool {
+	vlan, err := VLANFromFile(path, nil)
+	if err != nil {
+		return false
+	}
+	if vlan.Server.PrivateKey != "" {
+		return true
+	}
+	for _, client := range vlan.Clients {
+		if client.PrivateKey != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// openHistoryRepo opens the git repository holding the file at path, and returns the file's path within it
+func openHistoryRepo(path string) (*git.Repository, string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, "", err
+	}
+	repo, err := git.PlainOpenWithOptions(filepath.Dir(absPath), &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, "", err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil, "", err
+	}
+	relPath, err := filepath.Rel(worktree.Filesystem.Root(), absPath)
+	if err != nil {
+		return nil, "", err
+	}
+	return repo, filepath.ToSlash(relPath), nil
+}
+
+// historySignature names the author of history commits from git's user.name and user.email settings, or else from
+// the user running wg-vlan
+func historySignature(repo *git.Repository, now time.Time) *object.Signature {
+	signature := &object.Signature{When: now}
+	if cfg, err := repo.ConfigScoped(config.SystemScope); err == nil {
+		signature.Name = cfg.User.Name
+		signature.Email = cfg.User.Email
+	}
+	if signature.Name == "" {
+		signature.Name = os.Getenv("USER")
+	}
+	if signature.Name == "" {
+		signature.Name = "wg-vlan"
+	}
+	if signature.Email == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = "localhost"
+		}
+		signature.Email = signature.Name + "@" + hostname
+	}
+	return signature
+}
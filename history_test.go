@@ -0,0 +1,203 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+)
+
+func historyMessages(entries []HistoryEntry) []string {
+	messages := []string{}
+	for _, entry := range entries {
+		messages = append(messages, entry.Message)
+	}
+	return messages
+}
+
+func TestCommitConfigChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vlan.yaml")
+	vlan := loadTestVLAN(t, "basic.yaml")
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	if err := vlan.WriteTo(path); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	// Without a repository, one is created next to the config
+	logged := strings.Builder{}
+	if _, err := CommitConfigChange(path, "init wg-vlan 10.20.30.1/24", now, log.New(&logged, "", 0)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(logged.String(), "holds private keys, which are now in the git history") {
+		t.Errorf("expected a warning about committing private keys, got %q", logged.String())
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(path), ".git")); err != nil {
+		t.Fatalf("expected a git repository to be created: %v", err)
+	}
+
+	client, err := vlan.NewClient("carol", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := vlan.WriteTo(path); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	change := VLANChange{Command: "client-add", Args: []string{client.PeerName, client.Network}}
+	logged.Reset()
+	hash, err := CommitConfigChange(path, change.String(), now.Add(time.Minute), log.New(&logged, "", 0))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hash.IsZero() {
+		t.Errorf("expected a commit")
+	}
+
+	// Writing the same config again commits nothing
+	if err := vlan.WriteTo(path); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if logged.Len() > 0 {
+		t.Errorf("expected the private key warning only for the first commit, got %q", logged.String())
+	}
+
+	if hash, err := CommitConfigChange(path, "nothing", now.Add(2*time.Minute), nil); err != nil || !hash.IsZero() {
+		t.Errorf("expected no commit for an unchanged config, got %v, %v", hash, err)
+	}
+
+	entries, err := ConfigHistory(path, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"client-add carol 10.20.30.5", "init wg-vlan 10.20.30.1/24"}
+	if actual := historyMessages(entries); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected history %v, got %v", expected, actual)
+	}
+	if !entries[0].When.Equal(now.Add(time.Minute)) {
+		t.Errorf("expected commit time %v, got %v", now.Add(time.Minute), entries[0].When)
+	}
+
+	limited, err := ConfigHistory(path, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if actual := historyMessages(limited); !reflect.DeepEqual(actual, expected[:1]) {
+		t.Errorf("expected history %v, got %v", expected[:1], actual)
+	}
+}
+
+func TestConfigHistoryInSubdirectory(t *testing.T) {
+	root := t.TempDir()
+	if _, err := git.PlainInit(root, false); err != nil {
+		t.Fatalf("failed to initialize repository: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "vpn"), 0700); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	path := filepath.Join(root, "vpn", "vlan.yaml")
+	if err := loadTestVLAN(t, "basic.yaml").WriteTo(path); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	other := filepath.Join(root, "other.yaml")
+	if err := loadTestVLAN(t, "basic.yaml").WriteTo(other); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	if _, err := CommitConfigChange(path, "init", time.Now(), nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := CommitConfigChange(other, "init other", time.Now(), nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "vpn", ".git")); err == nil {
+		t.Errorf("expected the enclosing repository to be used")
+	}
+
+	// Only the commits touching the config are listed
+	entries, err := ConfigHistory(path, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if actual := historyMessages(entries); !reflect.DeepEqual(actual, []string{"init"}) {
+		t.Errorf("expected history [init], got %v", actual)
+	}
+}
+
+func TestConfigHistoryWithoutRepository(t *testing.T) {
+	if _, err := ConfigHistory(filepath.Join(t.TempDir(), "vlan.yaml"), 0); err == nil {
+		t.Errorf("expected an error without a repository")
+	}
+}
+
+func TestAPIGitHistory(t *testing.T) {
+	_, path := newTestAPI(t, "basic.yaml")
+	server := httptest.NewServer(&APIServer{ConfigFile: path, Token: testAPIToken, Recorder: ChangeRecorder{GitHistory: true}})
+	defer server.Close()
+
+	if resp, body := apiRequest(t, server, http.MethodPost, "/clients", `{"peer_name": "carol"}`); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", resp.StatusCode, body)
+	}
+	if resp, body := apiRequest(t, server, http.MethodDelete, "/clients/carol", ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", resp.StatusCode, body)
+	}
+
+	entries, err := ConfigHistory(path, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"client-remove carol", "client-add carol 10.20.30.5"}
+	if actual := historyMessages(entries); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected history %v, got %v", expected, actual)
+	}
+}
+
+func TestCommitConfigChangeRefusesStagedChanges(t *testing.T) {
+	root := t.TempDir()
+	repo, err := git.PlainInit(root, false)
+	if err != nil {
+		t.Fatalf("failed to initialize repository: %v", err)
+	}
+	path := filepath.Join(root, "vlan.yaml")
+	if err := loadTestVLAN(t, "basic.yaml").WriteTo(path); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "notes.txt"), []byte("unrelated\n"), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := worktree.Add("notes.txt"); err != nil {
+		t.Fatalf("failed to stage file: %v", err)
+	}
+
+	_, err = CommitConfigChange(path, "init", time.Now(), nil)
+	if err == nil || !strings.Contains(err.Error(), "other changes are staged") || !strings.Contains(err.Error(), "notes.txt") {
+		t.Fatalf("expected staged changes to be refused, got %v", err)
+	}
+	if _, err := repo.Head(); err == nil {
+		t.Errorf("expected nothing to be committed")
+	}
+
+	// Unstaged and untracked files are left alone
+	if _, err := worktree.Remove("notes.txt"); err != nil {
+		t.Fatalf("failed to unstage file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "notes.txt"), []byte("unrelated\n"), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if _, err := CommitConfigChange(path, "init", time.Now(), nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	entries, err := ConfigHistory(path, 0)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("expected one commit, got %v: %v", entries, err)
+	}
+}
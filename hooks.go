@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"gopkg.in/ini.v1"
+)
+
+// WG_QUICK_INTERFACE is substituted by wg-quick with the name of the interface being configured
+const WG_QUICK_INTERFACE = "%i"
+
+// PeerHooks are commands run by wg-quick around bringing the interface up or down. Each command is a Go template
+// expanded with HookTemplateData at export time.
+type PeerHooks struct {
+	PreUp    []string `yaml:"pre_up,omitempty" json:"pre_up,omitempty"`
+	PostUp   []string `yaml:"post_up,omitempty" json:"post_up,omitempty"`
+	PreDown  []string `yaml:"pre_down,omitempty" json:"pre_down,omitempty"`
+	PostDown []string `yaml:"post_down,omitempty" json:"post_down,omitempty"`
+}
+
+type HookTemplateData struct {
+	// Interface is the Wireguard interface name; wg-quick's own "%i" placeholder unless configured
+	Interface string
+	// PeerName is the name of the peer the config is exported for
+	PeerName string
+	// Address is the peer's own address in CIDR form
+	Address string
+	// Network is the VLAN subnet in CIDR form
+	Network string
+}
+
+func (hooks PeerHooks) keys() []struct {
+	name     string
+	commands []string
+} {
+	return []struct {
+		name     string
+		commands []string
+	}{
+		{"PreUp", hooks.PreUp},
+		{"PostUp", hooks.PostUp},
+		{"PreDown", hooks.PreDown},
+		{"PostDown", hooks.PostDown},
+	}
+}
+
+// empty reports whether no hooks are set
+func (hooks PeerHooks) empty() bool {
+	for _, key := range hooks.keys() {
+		if len(key.commands) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (hooks PeerHooks) Validate() (vWarnings []string, vError error) {
+	vErrors := []error{}
+	for _, key := range hooks.keys() {
+		for idx, command := range key.commands {
+			// A line break would end the key early and let the rest of the command add keys or sections to the export
+			if strings.ContainsAny(command, "\r\n") {
+				vErrors = append(vErrors, fmt.Errorf("%s[%d]: command must be a single line", key.name, idx))
+				continue
+			}
+			tmpl, err := parseHookTemplate(command)
+			if err == nil {
+				// Executing against empty data catches references to unknown variables
+				err = tmpl.Execute(io.Discard, HookTemplateData{})
+			}
+			if err != nil {
+				vErrors = append(vErrors, fmt.Errorf("%s[%d]: %w", key.name, idx, err))
+			}
+		}
+	}
+
+	if len(vErrors) > 0 {
+		vError = fmt.Errorf("validation failed: %w", errors.Join(vErrors...))
+	}
+	return
+}
+
+// Render expands the hook templates into the given INI section, as repeated keys
+func (hooks PeerHooks) Render(section *ini.Section, data HookTemplateData) error {
+	for _, key := range hooks.keys() {
+		for idx, command := range key.commands {
+			tmpl, err := parseHookTemplate(command)
+			if err != nil {
+				return fmt.Errorf("%s[%d]: %w", key.name, idx, err)
+			}
+			buf := strings.Builder{}
+			if err := tmpl.Execute(&buf, data); err != nil {
+				return fmt.Errorf("%s[%d]: %w", key.name, idx, err)
+			}
+			if _, err := section.NewKey(key.name, buf.String()); err != nil {
+				return fmt.Errorf("%s[%d]: %w", key.name, idx, err)
+			}
+		}
+	}
+	return nil
+}
+
+func parseHookTemplate(command string) (*template.Template, error) {
+	return template.New("hook").Option("missingkey=error").Parse(command)
+}
+
+// interfaceName returns the configured interface name, falling back to wg-quick's placeholder for it
+func interfaceName(configured string) string {
+	if configured == "" {
+		return WG_QUICK_INTERFACE
+	}
+	return configured
+}
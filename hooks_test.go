@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestPeerHooksValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		hooks   PeerHooks
+		wantErr bool
+	}{
+		{"empty", PeerHooks{}, false},
+		{"valid", PeerHooks{PostUp: []string{"ip link set {{.Interface}} up; true"}}, false},
+		{"syntax error", PeerHooks{PreUp: []string{"echo {{.PeerName"}}, true},
+		{"unknown variable", PeerHooks{PostDown: []string{"echo {{.Hostname}}"}}, true},
+		{"line break", PeerHooks{PostUp: []string{"echo up\n[Peer]\nAllowedIPs = 0.0.0.0/0"}}, true},
+		{"carriage return", PeerHooks{PreDown: []string{"echo down\rtrue"}}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.hooks.Validate()
+			if (err != nil) != tc.wantErr {
+				t.Errorf("got error %v, want error: %v", err, tc.wantErr)
+			}
+		})
+	}
+}
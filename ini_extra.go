@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/ini.v1"
+	"gopkg.in/yaml.v3"
+)
+
+// IniExtra is an ordered list of INI key overrides. In YAML it is a mapping whose values are either a single scalar,
+// or a list of scalars for keys that Wireguard allows to repeat (such as DNS or PostUp).
+type IniExtra []IniExtraEntry
+
+type IniExtraEntry struct {
+	Key    string
+	Values []string
+}
+
+func (extra *IniExtra) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: extra must be a mapping", node.Line)
+	}
+	// Values keep their original text, so that scalars like "off" or "0x1234" are not resolved to other types
+	*extra = IniExtra{}
+	for idx := 0; idx+1 < len(node.Content); idx += 2 {
+		key, valueNode := node.Content[idx], resolveAlias(node.Content[idx+1])
+		values := []string{}
+		switch valueNode.Kind {
+		case yaml.ScalarNode:
+			values = append(values, valueNode.Value)
+		case yaml.SequenceNode:
+			for _, item := range valueNode.Content {
+				if item = resolveAlias(item); item.Kind != yaml.ScalarNode {
+					return fmt.Errorf("line %d: extra values must be a scalar or a list of scalars", item.Line)
+				}
+				values = append(values, item.Value)
+			}
+		default:
+			return fmt.Errorf("line %d: extra values must be a scalar or a list of scalars", valueNode.Line)
+		}
+		*extra = append(*extra, IniExtraEntry{Key: key.Value, Values: values})
+	}
+	return nil
+}
+
+func (extra IniExtra) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, entry := range extra {
+		value := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, v := range entry.Values {
+			value.Content = append(value.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v})
+		}
+		if len(entry.Values) == 1 {
+			value = value.Content[0]
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: entry.Key}, value)
+	}
+	return node, nil
+}
+
+// Apply overrides keys in the given INI section, in order. Each key replaces any value the section already had for
+// it, and is repeated once per value. Hook keys are the exception: their commands run in addition to those rendered
+// from hooks, so they are added after them instead.
+func (extra IniExtra) Apply(section *ini.Section) error {
+	for _, entry := range extra {
+		if !isHookKey(entry.Key) {
+			section.DeleteKey(entry.Key)
+		}
+		for _, value := range entry.Values {
+			if _, err := section.NewKey(entry.Key, value); err != nil {
+				return fmt.Errorf("extra '%s': %w", entry.Key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Validate rejects keys and values that span several lines, which would add keys or sections of their own to the
+// exported INI
+func (extra IniExtra) Validate() (vWarnings []string, vError error) {
+	vErrors := []error{}
+	for _, entry := range extra {
+		if strings.ContainsAny(entry.Key, "\r\n") {
+			vErrors = append(vErrors, fmt.Errorf("key %q must be a single line", entry.Key))
+			continue
+		}
+		for idx, value := range entry.Values {
+			if strings.ContainsAny(value, "\r\n") {
+				vErrors = append(vErrors, fmt.Errorf("%s[%d]: value must be a single line", entry.Key, idx))
+			}
+		}
+	}
+
+	if len(vErrors) > 0 {
+		vError = fmt.Errorf("validation failed: %w", errors.Join(vErrors...))
+	}
+	return
+}
+
+// isHookKey reports whether an INI key is one of the wg-quick hooks that PeerHooks renders
+func isHookKey(key string) bool {
+	for _, hookKey := range (PeerHooks{}).keys() {
+		if hookKey.name == key {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalJSON renders the extras as a JSON object in their configured order, with the same value shapes as in YAML
+func (extra IniExtra) MarshalJSON() ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.WriteByte('{')
+	for idx, entry := range extra {
+		if idx > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		var value interface{} = entry.Values
+		if len(entry.Values) == 1 {
+			value = entry.Values[0]
+		}
+		encodedValue, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(encodedValue)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestIniExtraYAMLRoundTrip(t *testing.T) {
+	input := "Zeta: 1\nDNS:\n- 1.1.1.1\n- 8.8.8.8\nAlpha: \"on\"\n"
+
+	extra := IniExtra{}
+	if err := yaml.Unmarshal([]byte(input), &extra); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := IniExtra{
+		{Key: "Zeta", Values: []string{"1"}},
+		{Key: "DNS", Values: []string{"1.1.1.1", "8.8.8.8"}},
+		{Key: "Alpha", Values: []string{"on"}},
+	}
+	if !reflect.DeepEqual(extra, expected) {
+		t.Fatalf("got %#v, expected %#v", extra, expected)
+	}
+
+	output, err := marshalYAML(extra)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(output) != "Zeta: \"1\"\nDNS:\n  - 1.1.1.1\n  - 8.8.8.8\nAlpha: on\n" {
+		t.Errorf("unexpected YAML:\n%s", output)
+	}
+}
+
+func TestIniExtraYAMLInvalid(t *testing.T) {
+	for _, input := range []string{
+		"MTU: {nested: 1}\n",
+		"DNS: [[1.1.1.1]]\n",
+		"- MTU\n",
+	} {
+		extra := IniExtra{}
+		if err := yaml.Unmarshal([]byte(input), &extra); err == nil {
+			t.Errorf("expected error decoding %q", input)
+		}
+	}
+}
+
+func TestIniExtraApplyKeepsHooks(t *testing.T) {
+	iniFile := newWireguardIni()
+	section := iniFile.Section("Interface")
+	section.Key("MTU").SetValue("1500")
+	hooks := PeerHooks{PostUp: []string{"echo {{.Interface}} up"}, PostDown: []string{"echo down"}}
+	if err := hooks.Render(section, HookTemplateData{Interface: "wg0"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	extra := IniExtra{
+		{Key: "PostUp", Values: []string{"sysctl -w net.ipv4.ip_forward=1"}},
+		{Key: "MTU", Values: []string{"1420"}},
+	}
+	if err := extra.Apply(section); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	output, err := IniString(iniFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := strings.Join([]string{
+		"[Interface]",
+		"PostUp   = echo wg0 up",
+		"PostUp   = sysctl -w net.ipv4.ip_forward=1",
+		"PostDown = echo down",
+		"MTU      = 1420",
+		"",
+	}, "\n")
+	if output != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", output, expected)
+	}
+}
+
+func TestIniExtraValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		extra     IniExtra
+		errorPart string
+	}{
+		{"valid", IniExtra{{Key: "MTU", Values: []string{"1420"}}, {Key: "DNS", Values: []string{"1.1.1.1", "8.8.8.8"}}}, ""},
+		{"value line break", IniExtra{{Key: "DNS", Values: []string{"1.1.1.1", "8.8.8.8\n[Peer]"}}}, "DNS[1]: value must be a single line"},
+		{"value carriage return", IniExtra{{Key: "PostUp", Values: []string{"true\rfalse"}}}, "PostUp[0]: value must be a single line"},
+		{"key line break", IniExtra{{Key: "MTU\n[Peer]\nAllowedIPs", Values: []string{"0.0.0.0/0"}}}, "must be a single line"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.extra.Validate()
+			if tc.errorPart == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			} else if tc.errorPart != "" && (err == nil || !strings.Contains(err.Error(), tc.errorPart)) {
+				t.Errorf("expected error containing '%s', got %v", tc.errorPart, err)
+			}
+		})
+	}
+}
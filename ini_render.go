@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// WriteIni renders a Wireguard INI file in canonical form, so that exports are byte-for-byte reproducible regardless
+// of ini.v1's global formatting settings or platform line endings:
+//
+//   - sections are separated by one blank line, and preceded by their comment lines, each prefixed with "# "
+//   - keys are written in insertion order, with repeated keys once per value, and keys with no value omitted
+//   - "=" signs are aligned within each section, and values are never quoted
+//   - lines end with "\n"
+//
+// Keys and values spanning several lines are an error, since they would be read back as keys or sections of their own.
+func WriteIni(w io.Writer, iniFile *ini.File) error {
+	buf := strings.Builder{}
+
+	for _, section := range iniFile.Sections() {
+		if section.Name() == ini.DefaultSection && len(section.Keys()) == 0 {
+			continue
+		}
+
+		if buf.Len() > 0 {
+			buf.WriteString("\n")
+		}
+
+		if section.Comment != "" {
+			for _, line := range strings.Split(section.Comment, "\n") {
+				line = strings.TrimSpace(strings.TrimLeft(line, "#;"))
+				fmt.Fprintf(&buf, "# %s\n", line)
+			}
+		}
+		fmt.Fprintf(&buf, "[%s]\n", section.Name())
+
+		width := 0
+		for _, key := range section.Keys() {
+			if len(key.ValueWithShadows()) > 0 && len(key.Name()) > width {
+				width = len(key.Name())
+			}
+		}
+		for _, key := range section.Keys() {
+			for _, value := range key.ValueWithShadows() {
+				if strings.ContainsAny(key.Name(), "\r\n") || strings.ContainsAny(value, "\r\n") {
+					return fmt.Errorf("[%s] %q: keys and values must be single lines", section.Name(), key.Name())
+				}
+				fmt.Fprintf(&buf, "%-*s = %s\n", width, key.Name(), value)
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, buf.String())
+	return err
+}
+
+// IniString renders a Wireguard INI file in canonical form; see WriteIni
+func IniString(iniFile *ini.File) (string, error) {
+	buf := strings.Builder{}
+	if err := WriteIni(&buf, iniFile); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestServerIni(t *testing.T) {
+	for _, fixture := range []string{"basic", "missing_psk", "no_keepalive", "extra", "hooks", "hooks_extra"} {
+		t.Run(fixture, func(t *testing.T) {
+			vlan := loadTestVLAN(t, fixture+".yaml")
+
+			iniFile, err := vlan.ServerIni()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			output, err := IniString(iniFile)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertGolden(t, fixture+"_server.ini", output)
+		})
+	}
+}
+
+func TestClientIni(t *testing.T) {
+	for _, tc := range []struct {
+		fixture string
+		client  string
+	}{
+		{"basic", "alice"},
+		{"basic", "bob"},
+		{"missing_psk", "alice"},
+		{"no_keepalive", "alice"},
+		{"extra", "alice"},
+		{"extra", "bob"},
+		{"hooks", "alice"},
+		{"hooks_extra", "alice"},
+	} {
+		t.Run(fmt.Sprintf("%s/%s", tc.fixture, tc.client), func(t *testing.T) {
+			vlan := loadTestVLAN(t, tc.fixture+".yaml")
+
+			iniFile, err := vlan.ClientIni(tc.client)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			output, err := IniString(iniFile)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertGolden(t, fmt.Sprintf("%s_%s.ini", tc.fixture, tc.client), output)
+		})
+	}
+}
+
+func TestClientIniErrors(t *testing.T) {
+	vlan := loadTestVLAN(t, "basic.yaml")
+
+	if _, err := vlan.ClientIni("nobody"); err == nil {
+		t.Error("expected error for unknown client")
+	}
+	if _, err := vlan.ClientIni("phone"); err == nil {
+		t.Error("expected error for client without private key")
+	}
+}
+
+func TestWriteIniIgnoresGlobalFormatting(t *testing.T) {
+	vlan := loadTestVLAN(t, "basic.yaml")
+	iniFile, err := vlan.ServerIni()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected, err := IniString(iniFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	prettyFormat, lineBreak := ini.PrettyFormat, ini.LineBreak
+	ini.PrettyFormat, ini.LineBreak = false, "\r\n"
+	defer func() { ini.PrettyFormat, ini.LineBreak = prettyFormat, lineBreak }()
+
+	actual, err := IniString(iniFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if actual != expected {
+		t.Errorf("output changed with global ini formatting:\n%s", actual)
+	}
+}
+
+func TestWriteIniComments(t *testing.T) {
+	iniFile := newWireguardIni()
+	iniFile.Section("Interface").Comment = "first line\n; second line\n# third line"
+	iniFile.Section("Interface").Key("Address").SetValue("10.0.0.1/24")
+	iniFile.Section("Interface").Key("Empty").SetValue("")
+	iniFile.Section("Interface").Key("DNS").SetValue("10.0.0.1")
+	iniFile.Section("Interface").Key("DNS").AddShadow("10.0.0.2")
+
+	output, err := IniString(iniFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := strings.Join([]string{
+		"# first line",
+		"# second line",
+		"# third line",
+		"[Interface]",
+		"Address = 10.0.0.1/24",
+		"DNS     = 10.0.0.1",
+		"DNS     = 10.0.0.2",
+		"",
+	}, "\n")
+	if output != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", output, expected)
+	}
+}
+
+func TestWriteIniRejectsLineBreaks(t *testing.T) {
+	// Validation rejects such hooks, but the renderer must not emit them even if it is skipped
+	vlan := loadTestVLAN(t, "basic.yaml")
+	vlan.Server.Hooks.PostUp = []string{"echo up\n[Peer]\nPublicKey = ZK3+qu5D9HjC+GRRym7beOaIQDyHzfQnmnhS6o2653o=\nAllowedIPs = 0.0.0.0/0"}
+	iniFile, err := vlan.ServerIni()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if output, err := IniString(iniFile); err == nil || !strings.Contains(err.Error(), `[Interface] "PostUp": keys and values must be single lines`) {
+		t.Errorf("expected an error, got %v and output:\n%s", err, output)
+	}
+
+	iniFile = newWireguardIni()
+	iniFile.Section("Interface").Key("DNS").SetValue("10.0.0.1\r10.0.0.2")
+	if _, err := IniString(iniFile); err == nil {
+		t.Errorf("expected an error for a carriage return")
+	}
+}
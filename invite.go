@@ -0,0 +1,248 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+const INVITE_TOKEN_PREFIX = "wgv1"
+
+// INVITE_PRIVATE_KEY_PLACEHOLDER stands in for the private key in client configs built for redeemed invites, which
+// the client fills in with its own key
+const INVITE_PRIVATE_KEY_PLACEHOLDER = "<your private key>"
+
+// VLANInvite reserves a client name and address until the invite is redeemed with the client's public key, or expires
+type VLANInvite struct {
+	ID        string    `yaml:"id" json:"id" schema:"required"`
+	PeerName  string    `yaml:"peer_name" json:"peer_name" schema:"required"`
+	Network   string    `yaml:"network" json:"network" schema:"required"`
+	ExpiresAt time.Time `yaml:"expires_at" json:"expires_at" schema:"required"`
+}
+
+// inviteClaims is the signed content of an invite token
+type inviteClaims struct {
+	ID        string `json:"id"`
+	PeerName  string `json:"name"`
+	Network   string `json:"net"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+func (inv VLANInvite) Validate() (vWarnings []string, vError error) {
+	vErrors := []error{}
+	if inv.ID == "" {
+		vErrors = append(vErrors, errors.New("invite id unset"))
+	}
+	if inv.PeerName == "" {
+		vErrors = append(vErrors, errors.New("invite name unset"))
+	} else if err := checkFileNamePart(inv.PeerName); err != nil {
+		vErrors = append(vErrors, fmt.Errorf("invite name invalid: %w", err))
+	}
+	if _, _, err := parseCIDR(inv.Network); err != nil {
+		vErrors = append(vErrors, fmt.Errorf("invite network invalid (%s): %w", inv.Network, err))
+	}
+	if !time.Now().Before(inv.ExpiresAt) {
+		vWarnings = append(vWarnings, fmt.Sprintf("invite expired at %s; its address stays reserved until it is removed", inv.ExpiresAt.Format(time.RFC3339)))
+	}
+
+	if len(vErrors) > 0 {
+		vError = fmt.Errorf("validation failed: %w", errors.Join(vErrors...))
+	}
+	return
+}
+
+// NewInvite reserves a name and the next free address for a client, returning the invite and the token to hand out
+func (vlan *VLAN) NewInvite(name string, expiresAt time.Time) (*VLANInvite, string, error) {
+	if err := checkFileNamePart(name); err != nil {
+		return nil, "", fmt.Errorf("invalid client name: %w", err)
+	}
+	if err := vlan.checkNameFree(name); err != nil {
+		return nil, "", err
+	}
+	address, err := vlan.NextAddress()
+	if err != nil {
+		return nil, "", err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, "", fmt.Errorf("failed generating invite id: %w", err)
+	}
+	invite := &VLANInvite{
+		ID:        hex.EncodeToString(id),
+		PeerName:  name,
+		Network:   address.String(),
+		ExpiresAt: expiresAt.UTC().Truncate(time.Second),
+	}
+	token, err := vlan.inviteToken(*invite)
+	if err != nil {
+		return nil, "", err
+	}
+
+	vlan.Invites = append(vlan.Invites, invite)
+	return invite, token, nil
+}
+
+// RedeemInvite registers the client an invite token was minted for, with the given public key. The invite is used up.
+func (vlan *VLAN) RedeemInvite(token string, publicKeyBase64 string, now time.Time) (*VLANClient, error) {
+	claims, err := vlan.verifyInviteToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := slices.IndexFunc(vlan.Invites, func(inv *VLANInvite) bool { return inv.ID == claims.ID })
+	if idx < 0 {
+		return nil, errors.New("invite was already redeemed or revoked")
+	}
+	invite := vlan.Invites[idx]
+	if invite.PeerName != claims.PeerName || invite.Network != claims.Network || invite.ExpiresAt.Unix() != claims.ExpiresAt {
+		return nil, errors.New("invite token does not match its reservation")
+	}
+	if !now.Before(invite.ExpiresAt) {
+		return nil, fmt.Errorf("invite expired at %s", invite.ExpiresAt.Format(time.RFC3339))
+	}
+	if _, err := WireguardPublicKey(publicKeyBase64); err != nil {
+		return nil, fmt.Errorf("invalid public key '%s': %w", publicKeyBase64, err)
+	}
+	if owner := vlan.publicKeyOwner(publicKeyBase64); owner != "" {
+		return nil, fmt.Errorf("public key '%s' is already used by %s", publicKeyBase64, owner)
+	}
+
+	client := &VLANClient{
+		PeerName:  invite.PeerName,
+		Network:   invite.Network,
+		PublicKey: publicKeyBase64,
+	}
+	if _, err := client.EnsurePresharedKey(); err != nil {
+		return nil, err
+	}
+
+	vlan.Invites = slices.Delete(vlan.Invites, idx, idx+1)
+	vlan.Clients = append(vlan.Clients, client)
+	return client, nil
+}
+
+// publicKeyOwner names the peer that has the given public key, or returns "" if none has it
+func (vlan VLAN) publicKeyOwner(publicKey string) string {
+	server := vlan.Server
+	if serverKey, err := server.EnsurePublicKey(); err == nil && serverKey == publicKey {
+		return fmt.Sprintf("server '%s'", server.PeerName)
+	}
+	for _, client := range vlan.Clients {
+		clientCopy := *client
+		if clientKey, err := clientCopy.EnsurePublicKey(); err == nil && clientKey == publicKey {
+			return fmt.Sprintf("client '%s'", client.PeerName)
+		}
+	}
+	return ""
+}
+
+// ClientIniTemplate builds a client's config with a placeholder in place of its private key, for clients that keep
+// their own key
+func (vlan VLAN) ClientIniTemplate(clientName string) (*ini.File, error) {
+	idx := slices.IndexFunc(vlan.Clients, func(cl *VLANClient) bool { return cl.PeerName == clientName })
+	if idx < 0 {
+		return nil, fmt.Errorf("no such client: %s", clientName)
+	}
+	template := *vlan.Clients[idx]
+	template.PrivateKey = INVITE_PRIVATE_KEY_PLACEHOLDER
+	vlan.Clients = slices.Clone(vlan.Clients)
+	vlan.Clients[idx] = &template
+	return vlan.ClientIni(clientName)
+}
+
+// inviteSigningKey derives the key that signs invite tokens from the server's private key, so that no other secret
+// needs to be kept. Rotating the server key invalidates outstanding tokens.
+func (vlan VLAN) inviteSigningKey() ([]byte, error) {
+	privateKey, err := WireguardPrivateKey(vlan.Server.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server private key: %w", err)
+	}
+	mac := hmac.New(sha256.New, privateKey.Bytes())
+	mac.Write([]byte("wg-vlan invite signing key"))
+	return mac.Sum(nil), nil
+}
+
+func (vlan VLAN) inviteToken(invite VLANInvite) (string, error) {
+	payload, err := json.Marshal(inviteClaims{
+		ID:        invite.ID,
+		PeerName:  invite.PeerName,
+		Network:   invite.Network,
+		ExpiresAt: invite.ExpiresAt.Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	encodedPayload := base64.RawURLEncoding.EncodeToString(payload)
+	signature, err := vlan.inviteSignature(encodedPayload)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join([]string{INVITE_TOKEN_PREFIX, encodedPayload, base64.RawURLEncoding.EncodeToString(signature)}, "."), nil
+}
+
+func (vlan VLAN) inviteSignature(encodedPayload string) ([]byte, error) {
+	key, err := vlan.inviteSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(INVITE_TOKEN_PREFIX + "." + encodedPayload))
+	return mac.Sum(nil), nil
+}
+
+func (vlan VLAN) verifyInviteToken(token string) (*inviteClaims, error) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 || parts[0] != INVITE_TOKEN_PREFIX {
+		return nil, errors.New("malformed invite token")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed invite token")
+	}
+	expected, err := vlan.inviteSignature(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(signature, expected) {
+		return nil, errors.New("invalid invite token signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("malformed invite token")
+	}
+	claims := &inviteClaims{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, fmt.Errorf("malformed invite token: %w", err)
+	}
+	return claims, nil
+}
+
+// checkNameFree fails if a client or an invite already uses the name
+func (vlan VLAN) checkNameFree(name string) error {
+	if name == "" {
+		return errors.New("client may not have an empty name")
+	}
+	for _, client := range vlan.Clients {
+		if client.PeerName == name {
+			return fmt.Errorf("name is already in use: %s", name)
+		}
+	}
+	for _, invite := range vlan.Invites {
+		if invite.PeerName == name {
+			return fmt.Errorf("name is reserved by an invite: %s", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testInvitePublicKey = "LnIaiVPVBzkWAW0bU+Csq15/9Kd+890dJzUB5LLpMU0="
+
+func TestInviteRedeem(t *testing.T) {
+	vlan := loadTestVLAN(t, "basic.yaml")
+	now := time.Now()
+
+	invite, token, err := vlan.NewInvite("carol", now.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if invite.Network != "10.20.30.5" {
+		t.Errorf("expected invite to reserve the next free address, got %s", invite.Network)
+	}
+
+	// The reservation holds the name and address until the invite is redeemed
+	if _, err := vlan.NewClientPublic("carol", testInvitePublicKey); err == nil {
+		t.Errorf("expected reserved name to be rejected")
+	}
+	next, err := vlan.NextAddress()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if next.String() == invite.Network {
+		t.Errorf("expected reserved address to be skipped, got %s", next)
+	}
+
+	client, err := vlan.RedeemInvite(token, testInvitePublicKey, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if client.PeerName != "carol" || client.Network != invite.Network || client.PrivateKey != "" {
+		t.Errorf("unexpected redeemed client: %+v", client)
+	}
+	if len(vlan.Invites) != 0 {
+		t.Errorf("expected invite to be used up, got %v", vlan.Invites)
+	}
+	if _, err := vlan.RedeemInvite(token, testInvitePublicKey, now); err == nil || !strings.Contains(err.Error(), "already redeemed") {
+		t.Errorf("expected second redemption to fail, got %v", err)
+	}
+
+	// Preshared keys are random, so fix one for the golden file
+	client.PresharedKey = "F4gPf57S7FGZ5wcZDLK+4UnOl36YDLWp9MTZpZFfbjU="
+	iniFile, err := vlan.ClientIniTemplate("carol")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out, err := IniString(iniFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertGolden(t, "basic_invited_carol.ini", out)
+	if client.PrivateKey != "" {
+		t.Errorf("expected template not to modify the client")
+	}
+}
+
+func TestNewInviteRejectsPathNames(t *testing.T) {
+	for _, name := range []string{"../x", "a/b", `a\b`, ".."} {
+		vlan := loadTestVLAN(t, "basic.yaml")
+		if _, _, err := vlan.NewInvite(name, time.Now().Add(time.Hour)); err == nil || !strings.Contains(err.Error(), "invalid client name") {
+			t.Errorf("%s: expected invalid name error, got %v", name, err)
+		}
+		if len(vlan.Invites) != 0 {
+			t.Errorf("%s: expected no invite to be reserved", name)
+		}
+	}
+}
+
+func TestRedeemInviteErrors(t *testing.T) {
+	now := time.Now()
+	for _, tc := range []struct {
+		name      string
+		expiresAt time.Time
+		token     func(token string) string
+		publicKey string
+		errorPart string
+	}{
+		{"expired", now.Add(-time.Hour), nil, testInvitePublicKey, "invite expired"},
+		{"tampered", now.Add(time.Hour), func(token string) string {
+			parts := strings.Split(token, ".")
+			return strings.Join([]string{parts[0], parts[1] + "x", parts[2]}, ".")
+		}, testInvitePublicKey, "signature"},
+		{"malformed", now.Add(time.Hour), func(string) string { return "not-a-token" }, testInvitePublicKey, "malformed"},
+		{"bad public key", now.Add(time.Hour), nil, "not-a-key", "invalid public key"},
+		{"server's public key", now.Add(time.Hour), nil, "IQ4uMlUptaT8WyibXitKw+rCFOuyBP/SqIPQxSkD8GI=", "already used by server 'wg-vlan'"},
+		{"client's public key", now.Add(time.Hour), nil, "0RLQO1vcOdSIwJ3AFrwvinHPrAZD+Rv7RdpKioCj5E4=", "already used by client 'phone'"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			vlan := loadTestVLAN(t, "basic.yaml")
+			_, token, err := vlan.NewInvite("carol", tc.expiresAt)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.token != nil {
+				token = tc.token(token)
+			}
+			_, err = vlan.RedeemInvite(token, tc.publicKey, now)
+			if err == nil || !strings.Contains(err.Error(), tc.errorPart) {
+				t.Errorf("expected error containing '%s', got %v", tc.errorPart, err)
+			}
+			if len(vlan.Invites) != 1 {
+				t.Errorf("expected failed redemption to keep the invite")
+			}
+		})
+	}
+}
+
+func TestInviteTokenBoundToServerKey(t *testing.T) {
+	vlan := loadTestVLAN(t, "basic.yaml")
+	_, token, err := vlan.NewInvite("carol", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	other := loadTestVLAN(t, "basic.yaml")
+	other.Server.PrivateKey = "waIU1Hxrg5TlXR/mW7X3K7CD3RnXRtYjb96G/v2wh84="
+	other.Invites = vlan.Invites
+	if _, err := other.RedeemInvite(token, testInvitePublicKey, time.Now()); err == nil {
+		t.Errorf("expected token signed with another server key to be rejected")
+	}
+}
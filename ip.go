@@ -11,12 +11,12 @@ func ipAdd(ip net.IP, offset int64) net.IP {
 }
 
 func ipNetEdges(subnet net.IPNet) (net.IP, net.IP) {
-	subnetInt := big.NewInt(0).SetBytes(subnet.IP)
-	maskInt := big.NewInt(0).SetBytes(subnet.Mask)
-	inverseMask := big.NewInt(0).Not(maskInt)
-	firstIPInt := big.NewInt(0).And(subnetInt, maskInt)
-	lastIPInt := big.NewInt(0).Add(subnetInt, inverseMask)
-	return net.IP(firstIPInt.Bytes()), net.IP(lastIPInt.Bytes())
+	first := subnet.IP.Mask(subnet.Mask)
+	last := make(net.IP, len(first))
+	for idx := range first {
+		last[idx] = first[idx] | ^subnet.Mask[idx]
+	}
+	return first, last
 }
 
 func ipCompare(first net.IP, second net.IP) int {
@@ -49,7 +49,7 @@ func pickNextIP(subnet net.IPNet, takenIPs []net.IP, takenSubnets []net.IPNet) (
 		}
 
 		if takenSubnet != nil {
-			_, lastTakenIP := ipNetEdges(subnet)
+			_, lastTakenIP := ipNetEdges(*takenSubnet)
 			currentIP = ipAdd(lastTakenIP, 1)
 			continue
 		}
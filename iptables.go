@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// iptables limits chain names to 28 characters
+const IPTABLES_CHAIN_MAX_LENGTH = 28
+
+var iptablesChainInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// IptablesChain is the name of the custom chain holding this VLAN's rules, in both the filter and nat tables
+func (vlan VLAN) IptablesChain() string {
+	return vlan.iptablesChainName("VLAN-")
+}
+
+// IptablesHookChain is the name of the custom chain that FORWARD jumps to, which sends the VLAN's traffic on to
+// IptablesChain. Only the jump to it lives in FORWARD, so that reloading the rules leaves FORWARD alone.
+func (vlan VLAN) IptablesHookChain() string {
+	return vlan.iptablesChainName("VLANHOOK-")
+}
+
+func (vlan VLAN) iptablesChainName(prefix string) string {
+	chain := prefix + strings.ToUpper(iptablesChainInvalidChars.ReplaceAllString(vlan.Server.PeerName, "_"))
+	if len(chain) > IPTABLES_CHAIN_MAX_LENGTH {
+		chain = chain[:IPTABLES_CHAIN_MAX_LENGTH]
+	}
+	return chain
+}
+
+// Iptables renders the VLAN firewall as input for iptables-restore, or ip6tables-restore if the VLAN is IPv6
+func (vlan VLAN) Iptables() (string, error) {
+	_, vlanNetwork, err := parseCIDR(vlan.Server.Network)
+	if err != nil {
+		return "", fmt.Errorf("server had invalid network '%s': %w", vlan.Server.Network, err)
+	}
+
+	rules, err := vlan.compileFirewall()
+	if err != nil {
+		return "", err
+	}
+
+	fullTunnel, err := vlan.fullTunnelAddresses()
+	if err != nil {
+		return "", err
+	}
+
+	chain := vlan.IptablesChain()
+	hookChain := vlan.IptablesHookChain()
+	iptablesCommand := "iptables"
+	if vlanNetwork.IP.To4() == nil {
+		iptablesCommand = "ip6tables"
+	}
+	masquerade := vlan.Server.Masquerade && len(fullTunnel) > 0
+
+	// The file only touches chains it owns, and flushes them, so that loading it again changes nothing. The jumps
+	// from the built-in chains are added once, guarded by -C, since iptables-restore cannot check for a rule.
+	buf := strings.Builder{}
+	fmt.Fprintf(&buf, "# VLAN Server: %s\n", vlan.Server.PeerName)
+	fmt.Fprintf(&buf, "# Load with: %s-restore --noflush\n", iptablesCommand)
+	fmt.Fprintf(&buf, "# Then hook the chains in, once; reloading replaces their rules and keeps the hooks:\n")
+	fmt.Fprintf(&buf, "#   %[1]s -C FORWARD -j %[2]s 2>/dev/null || %[1]s -A FORWARD -j %[2]s\n", iptablesCommand, hookChain)
+	if masquerade {
+		fmt.Fprintf(&buf, "#   %[1]s -t nat -C POSTROUTING -j %[2]s 2>/dev/null || %[1]s -t nat -A POSTROUTING -j %[2]s\n", iptablesCommand, chain)
+	}
+
+	fmt.Fprintf(&buf, "*filter\n")
+	fmt.Fprintf(&buf, ":%s - [0:0]\n", hookChain)
+	fmt.Fprintf(&buf, ":%s - [0:0]\n", chain)
+	fmt.Fprintf(&buf, "-F %s\n", hookChain)
+	fmt.Fprintf(&buf, "-F %s\n", chain)
+	fmt.Fprintf(&buf, "-A %s -s %s -j %s\n", hookChain, vlanNetwork, chain)
+	fmt.Fprintf(&buf, "-A %s -d %s -j %s\n", hookChain, vlanNetwork, chain)
+	fmt.Fprintf(&buf, "-A %s -m conntrack --ctstate ESTABLISHED,RELATED -j ACCEPT\n", chain)
+
+	for _, rule := range rules {
+		fmt.Fprintf(&buf, "# %s\n", rule.Comment)
+		if len(rule.Sources) == 0 || len(rule.Destinations) == 0 {
+			fmt.Fprintf(&buf, "# skipped: no peers match\n")
+			continue
+		}
+
+		match := ""
+		if len(rule.Ports) > 0 {
+			ports := []string{}
+			for _, port := range rule.Ports {
+				ports = append(ports, fmt.Sprintf("%d", port))
+			}
+			match = fmt.Sprintf(" -p %s -m multiport --dports %s", rule.Proto, strings.Join(ports, ","))
+		} else if rule.Proto != "" {
+			match = fmt.Sprintf(" -p %s", layer4Proto(rule.Proto, vlanNetwork))
+		}
+
+		// iptables has no inline address sets, so each source/destination pair needs its own rule
+		for _, source := range rule.Sources {
+			for _, destination := range rule.Destinations {
+				fmt.Fprintf(&buf, "-A %s -s %s -d %s%s -j ACCEPT\n", chain, source, destination, match)
+			}
+		}
+	}
+
+	if len(fullTunnel) > 0 {
+		fmt.Fprintf(&buf, "# full-tunnel clients\n")
+		for _, source := range fullTunnel {
+			fmt.Fprintf(&buf, "-A %s -s %s ! -d %s -j ACCEPT\n", chain, source, vlanNetwork)
+		}
+	}
+
+	fmt.Fprintf(&buf, "-A %s -s %s -d %s -j DROP\n", chain, vlanNetwork, vlanNetwork)
+	fmt.Fprintf(&buf, "COMMIT\n")
+
+	if masquerade {
+		fmt.Fprintf(&buf, "*nat\n")
+		fmt.Fprintf(&buf, ":%s - [0:0]\n", chain)
+		fmt.Fprintf(&buf, "-F %s\n", chain)
+		for _, source := range fullTunnel {
+			fmt.Fprintf(&buf, "-A %s -s %s ! -d %s -j MASQUERADE\n", chain, source, vlanNetwork)
+		}
+		fmt.Fprintf(&buf, "COMMIT\n")
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIptablesPolicy(t *testing.T) {
+	vlan := loadTestVLAN(t, "policy.yaml")
+
+	ruleset, err := vlan.Iptables()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertGolden(t, "policy.iptables", ruleset)
+}
+
+func TestIptablesAllow(t *testing.T) {
+	vlan := loadTestVLAN(t, "allow.yaml")
+
+	ruleset, err := vlan.Iptables()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertGolden(t, "allow.iptables", ruleset)
+}
+
+func TestIptablesChain(t *testing.T) {
+	for _, tc := range []struct {
+		peerName string
+		expected string
+	}{
+		{"wg-vlan", "VLAN-WG-VLAN"},
+		{"hub.example.com", "VLAN-HUB_EXAMPLE_COM"},
+		{"a-very-long-server-peer-name", "VLAN-A-VERY-LONG-SERVER-PEER"},
+	} {
+		vlan := VLAN{Server: VLANServer{PeerName: tc.peerName}}
+		if chain := vlan.IptablesChain(); chain != tc.expected {
+			t.Errorf("chain for %s: got %s, expected %s", tc.peerName, chain, tc.expected)
+		}
+	}
+}
+
+// loadIptables applies a ruleset to a model of the firewall's chains, keyed by table and chain, the way
+// `iptables-restore --noflush` and the hook commands from the ruleset's header would
+func loadIptables(t *testing.T, chains map[string][]string, ruleset string) {
+	t.Helper()
+	table := ""
+	hooks := []string{}
+	for _, line := range strings.Split(ruleset, "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case strings.HasPrefix(line, "#   "):
+			_, hook, ok := strings.Cut(line, "|| ")
+			if !ok {
+				t.Fatalf("unexpected hook command: %s", line)
+			}
+			hooks = append(hooks, hook)
+		case line == "" || strings.HasPrefix(line, "#") || line == "COMMIT":
+		case strings.HasPrefix(line, "*"):
+			table = line[1:]
+		case strings.HasPrefix(line, ":"):
+			// Declaring a chain that exists flushes it, even with --noflush
+			chains[table+"/"+fields[0][1:]] = []string{}
+		case fields[0] == "-F":
+			chains[table+"/"+fields[1]] = []string{}
+		case fields[0] == "-A":
+			key := table + "/" + fields[1]
+			if _, ok := chains[key]; !ok {
+				t.Fatalf("rule appended to unknown chain %s: %s", key, line)
+			}
+			chains[key] = append(chains[key], strings.Join(fields[2:], " "))
+		default:
+			t.Fatalf("unexpected line: %s", line)
+		}
+	}
+
+	for _, hook := range hooks {
+		fields := strings.Fields(hook)[1:]
+		hookTable := "filter"
+		if fields[0] == "-t" {
+			hookTable, fields = fields[1], fields[2:]
+		}
+		key, rule := hookTable+"/"+fields[1], strings.Join(fields[2:], " ")
+		found := false
+		for _, existing := range chains[key] {
+			found = found || existing == rule
+		}
+		if !found {
+			chains[key] = append(chains[key], rule)
+		}
+	}
+}
+
+func TestIptablesReload(t *testing.T) {
+	for _, name := range []string{"policy.yaml", "allow.yaml"} {
+		ruleset, err := loadTestVLAN(t, name).Iptables()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+
+		chains := map[string][]string{
+			"filter/FORWARD":  {"-i eth1 -j ACCEPT"},
+			"nat/POSTROUTING": {"-o eth0 -j MASQUERADE"},
+		}
+		loadIptables(t, chains, ruleset)
+		loaded := map[string][]string{}
+		for key, rules := range chains {
+			loaded[key] = append([]string{}, rules...)
+		}
+		loadIptables(t, chains, ruleset)
+
+		if !reflect.DeepEqual(chains, loaded) {
+			t.Errorf("%s: reloading changed the firewall from\n%v\nto\n%v", name, loaded, chains)
+		}
+		if forward := chains["filter/FORWARD"]; len(forward) != 2 || forward[0] != "-i eth1 -j ACCEPT" {
+			t.Errorf("%s: expected FORWARD to keep its rule and gain one jump, got %v", name, forward)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// JSON renders the full VLAN definition as indented JSON, using the same field names as the YAML config. Public keys
+// are always filled in; with redact, private and preshared keys are left out, so the output is safe to share.
+func (vlan VLAN) JSON(redact bool) ([]byte, error) {
+	// Copy the server and clients, so that filling in and redacting keys leaves the VLAN untouched
+	server := vlan.Server
+	if _, err := server.EnsurePublicKey(); err != nil {
+		return nil, fmt.Errorf("server public key failed: %w", err)
+	}
+	clients := []*VLANClient{}
+	for _, client := range vlan.Clients {
+		client, err := client.exported(redact)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+	if redact {
+		server.PrivateKey = ""
+	}
+	vlan.Server = server
+	vlan.Clients = clients
+
+	out, err := json.MarshalIndent(vlan, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// exported copies a client with its public key filled in and, with redact, its private and preshared keys left out
+func (cl VLANClient) exported(redact bool) (*VLANClient, error) {
+	if _, err := cl.EnsurePublicKey(); err != nil {
+		return nil, fmt.Errorf("client '%s' public key failed: %w", cl.PeerName, err)
+	}
+	if redact {
+		cl.PrivateKey = ""
+		cl.PresharedKey = ""
+	}
+	return &cl, nil
+}
+
+// MarshalJSON leaves out unset hooks, which encoding/json's omitempty does not do for structs
+func (srv VLANServer) MarshalJSON() ([]byte, error) {
+	type plainServer VLANServer
+	out := struct {
+		plainServer
+		Hooks *PeerHooks `json:"hooks,omitempty"`
+	}{plainServer: plainServer(srv)}
+	if !srv.Hooks.empty() {
+		out.Hooks = &srv.Hooks
+	}
+	return json.Marshal(out)
+}
+
+// MarshalJSON leaves out unset hooks, as for VLANServer
+func (cl VLANClient) MarshalJSON() ([]byte, error) {
+	type plainClient VLANClient
+	out := struct {
+		plainClient
+		Hooks *PeerHooks `json:"hooks,omitempty"`
+	}{plainClient: plainClient(cl)}
+	if !cl.Hooks.empty() {
+		out.Hooks = &cl.Hooks
+	}
+	return json.Marshal(out)
+}
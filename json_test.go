@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestVLANJSON(t *testing.T) {
+	for _, fixture := range []struct {
+		name   string
+		redact bool
+		golden string
+	}{
+		{"basic.yaml", false, "basic.json"},
+		{"basic.yaml", true, "basic.redacted.json"},
+		{"extra.yaml", false, "extra.json"},
+	} {
+		t.Run(fixture.golden, func(t *testing.T) {
+			vlan := loadTestVLAN(t, fixture.name)
+			out, err := vlan.JSON(fixture.redact)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertGolden(t, fixture.golden, string(out))
+
+			if !json.Valid(out) {
+				t.Errorf("output is not valid JSON")
+			}
+		})
+	}
+}
+
+func TestVLANJSONRedactLeavesVLANUntouched(t *testing.T) {
+	vlan := loadTestVLAN(t, "basic.yaml")
+	if _, err := vlan.JSON(true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vlan.Server.PrivateKey == "" || vlan.Client("alice").PrivateKey == "" || vlan.Client("alice").PresharedKey == "" {
+		t.Errorf("redacting modified the VLAN's keys")
+	}
+}
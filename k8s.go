@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var k8sNameInvalidChars = regexp.MustCompile(`[^a-z0-9-]+`)
+var k8sDataKeyPattern = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+
+type K8sSecretMeta struct {
+	Name      string
+	Namespace string
+	Labels    map[string]string
+}
+
+type k8sSecret struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   k8sObjectMeta     `yaml:"metadata"`
+	Type       string            `yaml:"type"`
+	Data       map[string]string `yaml:"data"`
+}
+
+type k8sObjectMeta struct {
+	Name      string            `yaml:"name"`
+	Namespace string            `yaml:"namespace,omitempty"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+}
+
+// K8sSecret wraps exported files in a Kubernetes v1 Secret manifest, with one data key per file
+func K8sSecret(meta K8sSecretMeta, files []ExportFile) (string, error) {
+	if meta.Name == "" {
+		return "", errors.New("secret name not set")
+	}
+
+	secret := k8sSecret{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata: k8sObjectMeta{
+			Name:      meta.Name,
+			Namespace: meta.Namespace,
+			Labels:    meta.Labels,
+		},
+		Type: "Opaque",
+		Data: map[string]string{},
+	}
+	for _, f := range files {
+		if !k8sDataKeyPattern.MatchString(f.Name) {
+			return "", fmt.Errorf("invalid secret key: '%s'", f.Name)
+		}
+		if _, ok := secret.Data[f.Name]; ok {
+			return "", fmt.Errorf("duplicate secret key: %s", f.Name)
+		}
+		secret.Data[f.Name] = base64.StdEncoding.EncodeToString([]byte(f.Content))
+	}
+
+	manifest, err := marshalYAML(secret)
+	if err != nil {
+		return "", err
+	}
+	return string(manifest), nil
+}
+
+// K8sName converts a name into a valid Kubernetes object name (a DNS-1123 subdomain label)
+func K8sName(name string) string {
+	name = strings.Trim(k8sNameInvalidChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(name) > 63 {
+		name = strings.TrimRight(name[:63], "-")
+	}
+	return name
+}
+
+// ParseK8sLabels parses labels given as "key=value" strings
+func ParseK8sLabels(labels []string) (map[string]string, error) {
+	parsed := map[string]string{}
+	for _, label := range labels {
+		key, value, ok := strings.Cut(label, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid label, expected key=value: '%s'", label)
+		}
+		parsed[key] = value
+	}
+	return parsed, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestK8sSecret(t *testing.T) {
+	vlan := loadTestVLAN(t, "basic.yaml")
+
+	files := []ExportFile{}
+	for _, build := range []struct {
+		name  string
+		build func() (string, error)
+	}{
+		{"wg-vlan.conf", func() (string, error) {
+			iniFile, err := vlan.ServerIni()
+			if err != nil {
+				return "", err
+			}
+			return IniString(iniFile)
+		}},
+		{"alice.conf", func() (string, error) {
+			iniFile, err := vlan.ClientIni("alice")
+			if err != nil {
+				return "", err
+			}
+			return IniString(iniFile)
+		}},
+	} {
+		content, err := build.build()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		files = append(files, ExportFile{Name: build.name, Content: content, Secret: true})
+	}
+
+	manifest, err := K8sSecret(K8sSecretMeta{
+		Name:      "wg-vlan",
+		Namespace: "vpn",
+		Labels:    map[string]string{"app": "wireguard", "team": "net"},
+	}, files)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertGolden(t, "basic.k8s-secret.yaml", manifest)
+
+	decoded := struct {
+		Data map[string]string `yaml:"data"`
+	}{}
+	if err := yaml.Unmarshal([]byte(manifest), &decoded); err != nil {
+		t.Fatalf("manifest is not valid YAML: %v", err)
+	}
+	for _, f := range files {
+		content, err := base64.StdEncoding.DecodeString(decoded.Data[f.Name])
+		if err != nil {
+			t.Fatalf("%s is not base64: %v", f.Name, err)
+		}
+		if string(content) != f.Content {
+			t.Errorf("%s does not round trip:\n%s", f.Name, content)
+		}
+	}
+}
+
+func TestK8sSecretInvalid(t *testing.T) {
+	if _, err := K8sSecret(K8sSecretMeta{}, nil); err == nil {
+		t.Error("expected error for missing name")
+	}
+	if _, err := K8sSecret(K8sSecretMeta{Name: "x"}, []ExportFile{{Name: "a b.conf"}}); err == nil {
+		t.Error("expected error for invalid key")
+	}
+	if _, err := K8sSecret(K8sSecretMeta{Name: "x"}, []ExportFile{{Name: "a.conf"}, {Name: "a.conf"}}); err == nil {
+		t.Error("expected error for duplicate key")
+	}
+}
+
+func TestK8sName(t *testing.T) {
+	for input, expected := range map[string]string{
+		"wg-vlan":          "wg-vlan",
+		"WG VLAN / alice_": "wg-vlan-alice",
+		"--Hub.Example--":  "hub-example",
+	} {
+		if name := K8sName(input); name != expected {
+			t.Errorf("K8sName(%q): got %q, expected %q", input, name, expected)
+		}
+	}
+}
+
+func TestParseK8sLabels(t *testing.T) {
+	labels, err := ParseK8sLabels([]string{"app=wireguard", "empty="})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(labels, map[string]string{"app": "wireguard", "empty": ""}) {
+		t.Errorf("got %v", labels)
+	}
+	if _, err := ParseK8sLabels([]string{"novalue"}); err == nil {
+		t.Error("expected error for label without value")
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"crypto/ecdh"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"errors"
 )
@@ -57,3 +58,14 @@ func WireguardPublicKey(b64key string) (*ecdh.PublicKey, error) {
 	return key, nil
 
 }
+
+// PublicKeyFingerprint identifies a public key in the style of SSH, as "SHA256:" and the unpadded base64 of the key's
+// SHA-256 hash. Keys that do not parse have no fingerprint.
+func PublicKeyFingerprint(b64key string) string {
+	key, err := WireguardPublicKey(b64key)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(key.Bytes())
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
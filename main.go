@@ -10,17 +10,65 @@ import (
 func main() {
 	generateCommand := InitializeCommand{}
 	clientAddCommand := ClientAddCommand{}
+	clientRenameCommand := ClientRenameCommand{}
+	clientReaddressCommand := ClientReaddressCommand{}
+	renumberCommand := RenumberCommand{}
 	printIniCommand := PrintIniCommand{}
+	schemaCommand := SchemaCommand{}
+	migrateCommand := MigrateCommand{}
+	pruneCommand := PruneCommand{}
+	inviteCommand := InviteCommand{}
+	redeemCommand := RedeemCommand{}
+	serveCommand := ServeCommand{}
+	uiCommand := UICommand{}
+	tuiCommand := TUICommand{}
+	historyCommand := HistoryCommand{}
+	auditCommand := AuditCommand{}
 
 	var app = &cli.App{
 		Name:        "wg-conf",
 		Description: "An opinionated tool for managing Wireguard configuration files",
 		Authors:     []*cli.Author{{Name: "Filip Sufitchi", Email: "fsufitchi@gmail.com"}},
 		Suggest:     true,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:    DRY_RUN_FLAG,
+				Aliases: []string{"diff"},
+				Usage:   "print a diff of the changes to the config and server ini, without writing them",
+			},
+			&cli.BoolFlag{
+				Name:    GIT_HISTORY_FLAG,
+				Usage:   "commit every change to the config into the git repository holding it, creating one if needed",
+				EnvVars: []string{GIT_HISTORY_ENV},
+			},
+			&cli.PathFlag{
+				Name:    AUDIT_LOG_FLAG,
+				Usage:   "append every change to the config to this hash-chained audit log",
+				EnvVars: []string{AUDIT_LOG_ENV},
+			},
+			&cli.StringFlag{
+				Name:        AUDIT_ACTOR_FLAG,
+				Usage:       "who is making the changes, for the audit log",
+				DefaultText: "$SUDO_USER or $USER",
+			},
+		},
 		Commands: []*cli.Command{
 			generateCommand.Command(),
 			clientAddCommand.Command(),
+			clientRenameCommand.Command(),
+			clientReaddressCommand.Command(),
+			renumberCommand.Command(),
 			printIniCommand.Command(),
+			schemaCommand.Command(),
+			migrateCommand.Command(),
+			pruneCommand.Command(),
+			inviteCommand.Command(),
+			redeemCommand.Command(),
+			serveCommand.Command(),
+			uiCommand.Command(),
+			tuiCommand.Command(),
+			historyCommand.Command(),
+			auditCommand.Command(),
 		},
 	}
 	if err := app.Run(os.Args); err != nil {
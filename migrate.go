@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CONFIG_VERSION is the version of the config format this build reads and writes. Configs without a version key
+// predate versioning, and are version 0.
+const CONFIG_VERSION = 1
+
+const CONFIG_VERSION_KEY = "version"
+
+// configMigration upgrades a config document by one version. It edits the YAML node tree of the document's top-level
+// mapping, so that comments and the original text of values survive migration.
+type configMigration struct {
+	Description string
+	Migrate     func(doc *yaml.Node) error
+}
+
+// configMigrations holds the migration from each version to the next, indexed by the version it upgrades from. A
+// change to the config format appends a migration here and bumps CONFIG_VERSION.
+var configMigrations = []configMigration{
+	{
+		// Version 1 only introduced the version key, which migrateConfig stamps after every step
+		Description: "add the version key",
+		Migrate: func(doc *yaml.Node) error {
+			return nil
+		},
+	},
+}
+
+// configVersion reads the version from a config document's top-level mapping
+func configVersion(doc *yaml.Node) (int, error) {
+	for idx := 0; idx+1 < len(doc.Content); idx += 2 {
+		if doc.Content[idx].Value != CONFIG_VERSION_KEY {
+			continue
+		}
+		value := doc.Content[idx+1]
+		version, err := strconv.Atoi(value.Value)
+		if value.Kind != yaml.ScalarNode || err != nil || version < 0 {
+			return 0, fmt.Errorf("invalid config version: '%s'", value.Value)
+		}
+		return version, nil
+	}
+	return 0, nil
+}
+
+// setConfigVersion sets the version in a config document's top-level mapping, adding it at the top if it is missing
+func setConfigVersion(doc *yaml.Node, version int) {
+	value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(version)}
+	for idx := 0; idx+1 < len(doc.Content); idx += 2 {
+		if doc.Content[idx].Value == CONFIG_VERSION_KEY {
+			doc.Content[idx+1] = value
+			return
+		}
+	}
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: CONFIG_VERSION_KEY}
+	doc.Content = append([]*yaml.Node{key, value}, doc.Content...)
+}
+
+// migrateConfig upgrades a config document's top-level mapping one step at a time, to the version after the last of
+// the given migrations, returning the descriptions of the migrations it applied
+func migrateConfig(doc *yaml.Node, migrations []configMigration) ([]string, error) {
+	version, err := configVersion(doc)
+	if err != nil {
+		return nil, err
+	}
+	if version > len(migrations) {
+		return nil, fmt.Errorf("config version %d is newer than this build supports (%d)", version, len(migrations))
+	}
+
+	applied := []string{}
+	for ; version < len(migrations); version++ {
+		migration := migrations[version]
+		if err := migration.Migrate(doc); err != nil {
+			return nil, fmt.Errorf("migration from version %d (%s) failed: %w", version, migration.Description, err)
+		}
+		setConfigVersion(doc, version+1)
+		applied = append(applied, fmt.Sprintf("%d -> %d: %s", version, version+1, migration.Description))
+	}
+	return applied, nil
+}
+
+// parseConfigDocument parses config YAML into a node tree, returning the document and its top-level mapping
+func parseConfigDocument(data []byte) (*yaml.Node, *yaml.Node, error) {
+	document := &yaml.Node{}
+	if err := yaml.Unmarshal(data, document); err != nil {
+		return nil, nil, err
+	}
+	if document.Kind == 0 {
+		// An empty file holds no document at all
+		document = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	if len(document.Content) != 1 || document.Content[0].Kind != yaml.MappingNode {
+		return nil, nil, errors.New("config is not a YAML mapping")
+	}
+	return document, document.Content[0], nil
+}
+
+// ConfigFileVersion reads the version of a config file without decoding the rest of it
+func ConfigFileVersion(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read config file (%s): %w", path, err)
+	}
+	_, doc, err := parseConfigDocument(data)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode config file (%s): %w", path, err)
+	}
+	return configVersion(doc)
+}
+
+// loadConfigDocument parses config YAML and migrates it to CONFIG_VERSION in place, returning the descriptions of
+// the migrations it applied. Nodes that migrations keep retain their original positions.
+func loadConfigDocument(data []byte) (*yaml.Node, []string, error) {
+	document, doc, err := parseConfigDocument(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	version, err := configVersion(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	if version == CONFIG_VERSION {
+		return document, nil, nil
+	}
+	if len(configMigrations) != CONFIG_VERSION {
+		return nil, nil, fmt.Errorf("missing config migrations: have %d, need %d", len(configMigrations), CONFIG_VERSION)
+	}
+
+	applied, err := migrateConfig(doc, configMigrations)
+	if err != nil {
+		return nil, nil, err
+	}
+	return document, applied, nil
+}
+
+func encodeConfigDocument(document *yaml.Node) ([]byte, error) {
+	return marshalYAML(document)
+}
+
+// MigrateConfig reads a config file and migrates it in memory, returning the original and migrated text with the
+// migrations applied. Nothing is migrated for a config already at the current version.
+func MigrateConfig(path string) ([]byte, []byte, []string, error) {
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to read config file (%s): %w", path, err)
+	}
+	document, applied, err := loadConfigDocument(original)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to migrate config file (%s): %w", path, err)
+	}
+	if len(applied) == 0 {
+		return original, original, nil, nil
+	}
+
+	vlan, err := decodeConfigDocument(document, nil)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to decode migrated config: %w", err)
+	}
+	if _, vError := vlan.Validate(); vError != nil {
+		return nil, nil, nil, fmt.Errorf("migrated config is invalid: %w", vError)
+	}
+	migrated, err := encodeConfigDocument(document)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return original, migrated, applied, nil
+}
+
+// MigrateConfigFile upgrades a config file to CONFIG_VERSION in place, after copying the original to backupPath. It
+// returns the descriptions of the migrations it applied; if there were none, the file is left untouched.
+func MigrateConfigFile(path string, backupPath string) ([]string, error) {
+	configLock, err := lockConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer configLock.Close()
+
+	original, migrated, applied, err := MigrateConfig(path)
+	if err != nil || len(applied) == 0 {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(backupPath); !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("backup already exists: %s", backupPath)
+	}
+	if err := os.WriteFile(backupPath, original, info.Mode().Perm()); err != nil {
+		return nil, fmt.Errorf("failed to write backup (%s): %w", backupPath, err)
+	}
+	// The migrated document is written rather than the decoded VLAN, keeping comments and formatting
+	if err := writeFileAtomic(resolveConfigPath(path), migrated, info.Mode().Perm()); err != nil {
+		return nil, fmt.Errorf("failed to write config file (%s): %w", path, err)
+	}
+	return applied, nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+func TestConfigMigrationsCoverVersions(t *testing.T) {
+	if len(configMigrations) != CONFIG_VERSION {
+		t.Errorf("expected %d migrations for config version %d, got %d", CONFIG_VERSION, CONFIG_VERSION, len(configMigrations))
+	}
+}
+
+func TestMigrateConfigSteps(t *testing.T) {
+	renameKey := func(from, to string) configMigration {
+		return configMigration{
+			Description: "rename " + from,
+			Migrate: func(doc *yamlv3.Node) error {
+				for idx := 0; idx < len(doc.Content); idx += 2 {
+					if doc.Content[idx].Value == from {
+						doc.Content[idx].Value = to
+					}
+				}
+				return nil
+			},
+		}
+	}
+	migrations := []configMigration{renameKey("a", "b"), renameKey("b", "c"), renameKey("c", "d")}
+
+	for _, tc := range []struct {
+		name     string
+		input    string
+		expected string
+		applied  int
+	}{
+		{"unversioned", "a: 1 # kept\n", "version: 3\nd: 1 # kept\n", 3},
+		{"partial", "version: 1\nb: off\n", "version: 3\nd: off\n", 2},
+		{"current", "version: 3\nd: 1\n", "version: 3\nd: 1\n", 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			document, doc, err := parseConfigDocument([]byte(tc.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			applied, err := migrateConfig(doc, migrations)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			out, err := encodeConfigDocument(document)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(out) != tc.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tc.expected, out)
+			}
+			if len(applied) != tc.applied {
+				t.Errorf("expected %d migrations applied, got %v", tc.applied, applied)
+			}
+		})
+	}
+}
+
+func TestMigrateConfigErrors(t *testing.T) {
+	failing := configMigration{
+		Description: "fail",
+		Migrate: func(doc *yamlv3.Node) error {
+			return errors.New("broken")
+		},
+	}
+
+	for _, tc := range []struct {
+		name       string
+		input      string
+		migrations []configMigration
+		errPart    string
+	}{
+		{"too new", "version: 5\n", configMigrations, "newer than this build supports"},
+		{"invalid version", "version: latest\n", configMigrations, "invalid config version"},
+		{"negative version", "version: -1\n", configMigrations, "invalid config version"},
+		{"failed step", "a: 1\n", []configMigration{failing}, "migration from version 0 (fail) failed: broken"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, doc, err := parseConfigDocument([]byte(tc.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			_, err = migrateConfig(doc, tc.migrations)
+			if err == nil || !strings.Contains(err.Error(), tc.errPart) {
+				t.Errorf("expected error containing %q, got %v", tc.errPart, err)
+			}
+		})
+	}
+}
+
+func TestVLANFromFileMigratesUnversioned(t *testing.T) {
+	vlan := loadTestVLAN(t, "basic.yaml")
+	if vlan.Version != CONFIG_VERSION {
+		t.Errorf("expected version %d, got %d", CONFIG_VERSION, vlan.Version)
+	}
+}
+
+func TestMigrateConfigFile(t *testing.T) {
+	original, err := os.ReadFile(filepath.Join("testdata", "basic.yaml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "vlan.yaml")
+	backupPath := path + ".bak"
+	if err := os.WriteFile(path, original, 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	applied, err := MigrateConfigFile(path, backupPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(applied) != CONFIG_VERSION {
+		t.Errorf("expected %d migrations applied, got %v", CONFIG_VERSION, applied)
+	}
+
+	backup, err := os.ReadFile(backupPath)
+	if err != nil {
+		t.Fatalf("backup not written: %v", err)
+	}
+	if string(backup) != string(original) {
+		t.Errorf("backup does not match the original config")
+	}
+
+	migrated, err := VLANFromFile(path, nil)
+	if err != nil {
+		t.Fatalf("migrated config failed to load: %v", err)
+	}
+	if version, err := ConfigFileVersion(path); err != nil || version != CONFIG_VERSION {
+		t.Errorf("expected file version %d, got %d (%v)", CONFIG_VERSION, version, err)
+	}
+	migratedJSON, err := migrated.JSON(false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	originalJSON, err := loadTestVLAN(t, "basic.yaml").JSON(false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(migratedJSON) != string(originalJSON) {
+		t.Errorf("migration changed the config's contents")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "version: 1\n") {
+		t.Errorf("expected the version key at the top of the migrated file, got:\n%s", data)
+	}
+
+	applied, err = MigrateConfigFile(path, filepath.Join(dir, "second.bak"))
+	if err != nil || len(applied) != 0 {
+		t.Errorf("expected an up-to-date config to be left alone, got %v, %v", applied, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "second.bak")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected no backup for an up-to-date config")
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"path"
+
+	"gopkg.in/ini.v1"
+)
+
+// DEFAULT_INTERFACE names the Wireguard interface for export formats that, unlike wg-quick, cannot infer it
+const DEFAULT_INTERFACE = "wg0"
+
+const DEFAULT_NETWORKD_KEY_DIR = "/etc/systemd/network"
+
+// NETWORKD_GROUP is the group systemd-networkd runs as, which must be able to read the key files
+const NETWORKD_GROUP = "systemd-network"
+
+// ExportFile is one file of a multi-file export
+type ExportFile struct {
+	Name    string
+	Content string
+	// Secret files contain private or preshared keys, and should be written readable only by their owner
+	Secret bool
+	// Group, if set, must also be able to read a secret file, which is then written 0640 and given to this group
+	Group string
+}
+
+// Networkd renders the server as systemd-networkd .netdev and .network files. Keys are referenced as files in keyDir
+// rather than inlined; the key files themselves are included in the result, marked secret.
+func (vlan VLAN) Networkd(keyDir string) ([]ExportFile, error) {
+	iface := vlan.Server.Interface
+	if iface == "" {
+		iface = DEFAULT_INTERFACE
+	}
+
+	_, vlanNetwork, err := parseCIDR(vlan.Server.Network)
+	if err != nil {
+		return nil, fmt.Errorf("server had invalid network '%s': %w", vlan.Server.Network, err)
+	}
+
+	privateKeyFile := ExportFile{Name: iface + ".key", Content: vlan.Server.PrivateKey + "\n", Secret: true, Group: NETWORKD_GROUP}
+	secretFiles := []ExportFile{privateKeyFile}
+
+	netdev := newWireguardIni()
+	netdev.Section("NetDev").Comment = fmt.Sprintf("# VLAN Server: %s", vlan.Server.PeerName)
+	netdev.Section("NetDev").Key("Name").SetValue(iface)
+	netdev.Section("NetDev").Key("Kind").SetValue("wireguard")
+	netdev.Section("NetDev").Key("Description").SetValue(fmt.Sprintf("wg-vlan server %s", vlan.Server.PeerName))
+
+	netdev.Section("WireGuard").Key("PrivateKeyFile").SetValue(path.Join(keyDir, privateKeyFile.Name))
+	netdev.Section("WireGuard").Key("ListenPort").SetValue(fmt.Sprintf("%d", vlan.Server.ListenPort))
+
+	routes := []string{}
+	for _, client := range vlan.Clients {
+		if client.Disabled {
+			continue
+		}
+		sec, _ := netdev.NewSection("WireGuardPeer")
+		sec.Comment = fmt.Sprintf("# VLAN Client: %s", client.PeerName)
+
+		publicKey, err := client.EnsurePublicKey()
+		if err != nil {
+			return nil, fmt.Errorf("peer failed '%s': %w", client.PeerName, err)
+		}
+		sec.Key("PublicKey").SetValue(publicKey)
+
+		if client.PresharedKey != "" {
+			presharedKeyFile := ExportFile{
+				Name:    fmt.Sprintf("%s-%s.psk", iface, client.PeerName),
+				Content: client.PresharedKey + "\n",
+				Secret:  true,
+				Group:   NETWORKD_GROUP,
+			}
+			secretFiles = append(secretFiles, presharedKeyFile)
+			sec.Key("PresharedKeyFile").SetValue(path.Join(keyDir, presharedKeyFile.Name))
+		}
+
+		clientIP, err := ensureIPWithCIDR(client.Network)
+		if err != nil {
+			return nil, fmt.Errorf("peer failed '%s': %w", client.PeerName, err)
+		}
+		sec.Key("AllowedIPs").SetValue(clientIP)
+
+		if vlan.KeepAlive != 0 {
+			sec.Key("PersistentKeepalive").SetValue(fmt.Sprintf("%d", vlan.KeepAlive))
+		}
+
+		// networkd shares wg-quick's names for the [Peer] keys that are likely to be overridden
+		if err := client.PeerExtra.Apply(sec); err != nil {
+			return nil, fmt.Errorf("peer failed '%s': %w", client.PeerName, err)
+		}
+
+		newRoutes, err := networkdRoutes(sec, vlanNetwork)
+		if err != nil {
+			return nil, fmt.Errorf("peer failed '%s': %w", client.PeerName, err)
+		}
+		routes = append(routes, newRoutes...)
+	}
+
+	network := newWireguardIni()
+	network.Section("Match").Comment = fmt.Sprintf("# VLAN Server: %s", vlan.Server.PeerName)
+	network.Section("Match").Key("Name").SetValue(iface)
+	network.Section("Network").Key("Address").SetValue(vlan.Server.Network)
+	for _, route := range routes {
+		sec, _ := network.NewSection("Route")
+		sec.Key("Destination").SetValue(route)
+	}
+
+	files := []ExportFile{}
+	for _, f := range []struct {
+		name    string
+		iniFile *ini.File
+	}{
+		{iface + ".netdev", netdev},
+		{iface + ".network", network},
+	} {
+		content, err := IniString(f.iniFile)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, ExportFile{Name: f.name, Content: content})
+	}
+
+	return append(files, secretFiles...), nil
+}
+
+// networkdRoutes lists the allowed IPs of a peer section that lie outside the VLAN network, and so are not covered by
+// the route networkd adds for the interface address
+func networkdRoutes(peerSection *ini.Section, vlanNetwork *net.IPNet) ([]string, error) {
+	routes := []string{}
+	for _, allowedIP := range peerSection.Key("AllowedIPs").ValueWithShadows() {
+		ip, ipNet, err := parseCIDR(allowedIP)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed IP '%s': %w", allowedIP, err)
+		}
+		if !vlanNetwork.Contains(ip) {
+			routes = append(routes, ipNet.String())
+		}
+	}
+	return routes, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNetworkd(t *testing.T) {
+	for _, fixture := range []string{"basic", "extra"} {
+		t.Run(fixture, func(t *testing.T) {
+			vlan := loadTestVLAN(t, fixture+".yaml")
+
+			files, err := vlan.Networkd(DEFAULT_NETWORKD_KEY_DIR)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			buf := strings.Builder{}
+			for _, f := range files {
+				buf.WriteString("### " + f.Name)
+				if f.Secret {
+					buf.WriteString(" (secret)")
+				}
+				buf.WriteString("\n" + f.Content)
+			}
+			assertGolden(t, fixture+".networkd", buf.String())
+		})
+	}
+}
+
+func TestNetworkdNoSecretsInline(t *testing.T) {
+	vlan := loadTestVLAN(t, "basic.yaml")
+
+	files, err := vlan.Networkd("/run/keys")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, f := range files {
+		if f.Secret {
+			continue
+		}
+		for _, secret := range []string{vlan.Server.PrivateKey, vlan.Clients[0].PrivateKey, vlan.Clients[0].PresharedKey} {
+			if strings.Contains(f.Content, secret) {
+				t.Errorf("%s contains secret %s", f.Name, secret)
+			}
+		}
+		if strings.HasSuffix(f.Name, ".netdev") && !strings.Contains(f.Content, "PrivateKeyFile = /run/keys/wg0.key") {
+			t.Errorf("%s does not reference the key directory:\n%s", f.Name, f.Content)
+		}
+	}
+}
+
+func TestValidateFileNames(t *testing.T) {
+	for _, name := range []string{"../../etc/x", "a/b", `a\b`, ".."} {
+		vlan := loadTestVLAN(t, "basic.yaml")
+		vlan.Clients[0].PeerName = name
+		if _, err := vlan.Validate(); err == nil || !strings.Contains(err.Error(), "client name invalid") {
+			t.Errorf("expected %q to be rejected, got %v", name, err)
+		}
+	}
+
+	vlan := loadTestVLAN(t, "basic.yaml")
+	vlan.Server.Interface = "../wg0"
+	if _, err := vlan.Validate(); err == nil || !strings.Contains(err.Error(), "interface invalid") {
+		t.Errorf("expected interface to be rejected, got %v", err)
+	}
+}
+
+func TestWriteExportFile(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, _, err := writeExportFile(dir, ExportFile{Name: "../escape.conf"}); err == nil {
+		t.Errorf("expected a name outside the output directory to be refused")
+	}
+
+	// An existing file is replaced, rather than written over with its old mode
+	existing := filepath.Join(dir, "wg0.key")
+	if err := os.WriteFile(existing, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		file ExportFile
+		mode os.FileMode
+	}{
+		{ExportFile{Name: "wg0.netdev"}, 0644},
+		{ExportFile{Name: "wg0.key", Secret: true, Group: NETWORKD_GROUP}, 0640},
+		{ExportFile{Name: "wg0.conf", Secret: true}, 0600},
+	} {
+		outputPath, mode, err := writeExportFile(dir, test.file)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		info, err := os.Stat(outputPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mode != test.mode || info.Mode().Perm() != test.mode {
+			t.Errorf("%s: expected mode %04o, got %04o (reported %04o)", test.file.Name, test.mode, info.Mode().Perm(), mode)
+		}
+	}
+	if err := os.Link(filepath.Join(dir, "wg0.conf"), filepath.Join(dir, "wg0.conf.link")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := writeExportFile(dir, ExportFile{Name: "wg0.conf", Content: "new\n", Secret: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if linked, _ := os.ReadFile(filepath.Join(dir, "wg0.conf.link")); len(linked) != 0 {
+		t.Errorf("expected the old file to be replaced rather than written over, got %q through a link to it", linked)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 4 {
+		t.Errorf("expected no temporary files to be left behind, got %v", entries)
+	}
+}
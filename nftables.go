@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+const NFTABLES_TABLE = "wg_vlan"
+
+func (vlan VLAN) Nftables() (string, error) {
+	_, vlanNetwork, err := parseCIDR(vlan.Server.Network)
+	if err != nil {
+		return "", fmt.Errorf("server had invalid network '%s': %w", vlan.Server.Network, err)
+	}
+
+	rules, err := vlan.compileFirewall()
+	if err != nil {
+		return "", err
+	}
+
+	fullTunnel, err := vlan.fullTunnelAddresses()
+	if err != nil {
+		return "", err
+	}
+
+	family := nftAddressFamily(vlanNetwork)
+	subnetMatch := fmt.Sprintf("%s saddr %s %s daddr %s", family, vlanNetwork, family, vlanNetwork)
+
+	buf := strings.Builder{}
+	fmt.Fprintf(&buf, "# VLAN Server: %s\n", vlan.Server.PeerName)
+	// Declaring then deleting the table makes the ruleset idempotent under `nft -f`
+	fmt.Fprintf(&buf, "table inet %s\n", NFTABLES_TABLE)
+	fmt.Fprintf(&buf, "delete table inet %s\n\n", NFTABLES_TABLE)
+
+	fmt.Fprintf(&buf, "table inet %s {\n", NFTABLES_TABLE)
+	fmt.Fprintf(&buf, "\tchain forward {\n")
+	fmt.Fprintf(&buf, "\t\ttype filter hook forward priority filter; policy accept;\n\n")
+	fmt.Fprintf(&buf, "\t\t%s ct state established,related accept\n", subnetMatch)
+
+	for _, rule := range rules {
+		fmt.Fprintf(&buf, "\n\t\t# %s\n", rule.Comment)
+		if len(rule.Sources) == 0 || len(rule.Destinations) == 0 {
+			fmt.Fprintf(&buf, "\t\t# skipped: no peers match\n")
+			continue
+		}
+
+		statement := []string{
+			fmt.Sprintf("%s saddr %s", family, nftAddressSet(rule.Sources)),
+			fmt.Sprintf("%s daddr %s", family, nftAddressSet(rule.Destinations)),
+		}
+		if len(rule.Ports) > 0 {
+			ports := []string{}
+			for _, port := range rule.Ports {
+				ports = append(ports, fmt.Sprintf("%d", port))
+			}
+			statement = append(statement, fmt.Sprintf("%s dport { %s }", rule.Proto, strings.Join(ports, ", ")))
+		} else if rule.Proto != "" {
+			statement = append(statement, fmt.Sprintf("meta l4proto %s", layer4Proto(rule.Proto, vlanNetwork)))
+		}
+		statement = append(statement, "accept")
+
+		fmt.Fprintf(&buf, "\t\t%s\n", strings.Join(statement, " "))
+	}
+
+	if len(fullTunnel) > 0 {
+		fmt.Fprintf(&buf, "\n\t\t# full-tunnel clients\n")
+		fmt.Fprintf(&buf, "\t\t%s saddr %s %s daddr != %s accept\n", family, nftAddressSet(fullTunnel), family, vlanNetwork)
+	}
+
+	fmt.Fprintf(&buf, "\n\t\t%s drop\n", subnetMatch)
+	fmt.Fprintf(&buf, "\t}\n")
+
+	if vlan.Server.Masquerade && len(fullTunnel) > 0 {
+		fmt.Fprintf(&buf, "\n\tchain postrouting {\n")
+		fmt.Fprintf(&buf, "\t\ttype nat hook postrouting priority srcnat; policy accept;\n\n")
+		fmt.Fprintf(&buf, "\t\t%s saddr %s %s daddr != %s masquerade\n", family, nftAddressSet(fullTunnel), family, vlanNetwork)
+		fmt.Fprintf(&buf, "\t}\n")
+	}
+
+	fmt.Fprintf(&buf, "}\n")
+
+	return buf.String(), nil
+}
+
+func nftAddressFamily(network *net.IPNet) string {
+	if network.IP.To4() != nil {
+		return "ip"
+	}
+	return "ip6"
+}
+
+func nftAddressSet(addresses []*net.IPNet) string {
+	elements := []string{}
+	for _, address := range addresses {
+		elements = append(elements, addressString(address))
+	}
+	return fmt.Sprintf("{ %s }", strings.Join(elements, ", "))
+}
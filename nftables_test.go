@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNftablesPolicy(t *testing.T) {
+	vlan := loadTestVLAN(t, "policy.yaml")
+
+	ruleset, err := vlan.Nftables()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertGolden(t, "policy.nft", ruleset)
+}
+
+func TestNftablesNoPolicy(t *testing.T) {
+	vlan := loadTestVLAN(t, "policy.yaml")
+	vlan.Policy = nil
+
+	ruleset, err := vlan.Nftables()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertGolden(t, "policy_empty.nft", ruleset)
+}
+
+func TestNftablesAllow(t *testing.T) {
+	vlan := loadTestVLAN(t, "allow.yaml")
+
+	ruleset, err := vlan.Nftables()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertGolden(t, "allow.nft", ruleset)
+}
+
+func TestPolicyRuleValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		rule    PolicyRule
+		wantErr bool
+	}{
+		{"valid", PolicyRule{From: []string{"laptops"}, To: []string{"servers"}, Proto: "tcp", Ports: []uint{22}}, false},
+		{"no source", PolicyRule{To: []string{"servers"}}, true},
+		{"ports without proto", PolicyRule{From: []string{"all"}, To: []string{"all"}, Ports: []uint{22}}, true},
+		{"unknown proto", PolicyRule{From: []string{"all"}, To: []string{"all"}, Proto: "sctp"}, true},
+		{"port out of range", PolicyRule{From: []string{"all"}, To: []string{"all"}, Proto: "udp", Ports: []uint{70000}}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.rule.Validate()
+			if (err != nil) != tc.wantErr {
+				t.Errorf("got error %v, want error: %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePolicyGroupNames(t *testing.T) {
+	vlan := loadTestVLAN(t, "policy.yaml")
+	vlan.Policy = append(vlan.Policy, PolicyRule{From: []string{"lap-tops"}, To: []string{"all"}})
+	_, err := vlan.Validate()
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("policy[%d]: invalid group name: 'lap-tops'", len(vlan.Policy)-1)) {
+		t.Errorf("expected an invalid group name error, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// NMConnection renders a client's config as a NetworkManager keyfile, for /etc/NetworkManager/system-connections. It
+// is translated from the client's INI, so extra overrides such as DNS and MTU carry over.
+func (vlan VLAN) NMConnection(clientName string, autoconnect bool) (string, error) {
+	iniFile, err := vlan.ClientIni(clientName)
+	if err != nil {
+		return "", err
+	}
+	client := vlan.Client(clientName)
+	clientPublicKey, err := client.EnsurePublicKey()
+	if err != nil {
+		return "", fmt.Errorf("client '%s' public key failed: %w", clientName, err)
+	}
+
+	iface := client.Interface
+	if iface == "" {
+		iface = DEFAULT_INTERFACE
+	}
+
+	ifaceSection := iniFile.Section("Interface")
+	peerSection := iniFile.Section("Peer")
+	publicKey := peerSection.Key("PublicKey").String()
+
+	buf := strings.Builder{}
+	fmt.Fprintf(&buf, "# VLAN Client: %s\n", client.PeerName)
+
+	fmt.Fprintf(&buf, "[connection]\n")
+	fmt.Fprintf(&buf, "id=%s\n", vlan.Server.PeerName)
+	fmt.Fprintf(&buf, "uuid=%s\n", nmConnectionUUID(vlan.Server.PeerName, clientPublicKey))
+	fmt.Fprintf(&buf, "type=wireguard\n")
+	fmt.Fprintf(&buf, "interface-name=%s\n", iface)
+	fmt.Fprintf(&buf, "autoconnect=%t\n", autoconnect)
+
+	fmt.Fprintf(&buf, "\n[wireguard]\n")
+	fmt.Fprintf(&buf, "private-key=%s\n", ifaceSection.Key("PrivateKey").String())
+	for _, key := range []struct{ ini, nm string }{
+		{"ListenPort", "listen-port"},
+		{"FwMark", "fwmark"},
+		{"MTU", "mtu"},
+	} {
+		if ifaceSection.HasKey(key.ini) {
+			fmt.Fprintf(&buf, "%s=%s\n", key.nm, ifaceSection.Key(key.ini).String())
+		}
+	}
+
+	fmt.Fprintf(&buf, "\n[wireguard-peer.%s]\n", publicKey)
+	if peerSection.HasKey("Endpoint") {
+		fmt.Fprintf(&buf, "endpoint=%s\n", peerSection.Key("Endpoint").String())
+	}
+	if peerSection.HasKey("PresharedKey") {
+		fmt.Fprintf(&buf, "preshared-key=%s\n", peerSection.Key("PresharedKey").String())
+		// Flags of 0 store the key in this file, rather than asking a secret agent for it
+		fmt.Fprintf(&buf, "preshared-key-flags=0\n")
+	}
+	if peerSection.HasKey("PersistentKeepalive") {
+		fmt.Fprintf(&buf, "persistent-keepalive=%s\n", peerSection.Key("PersistentKeepalive").String())
+	}
+	fmt.Fprintf(&buf, "allowed-ips=%s\n", nmList(splitIniList(peerSection.Key("AllowedIPs").ValueWithShadows())))
+
+	// wg-quick's DNS key mixes name servers and search domains
+	dnsServers := map[bool][]string{}
+	dnsSearch := []string{}
+	for _, dns := range splitIniList(ifaceSection.Key("DNS").ValueWithShadows()) {
+		if ip := net.ParseIP(dns); ip != nil {
+			dnsServers[ip.To4() != nil] = append(dnsServers[ip.To4() != nil], dns)
+		} else {
+			dnsSearch = append(dnsSearch, dns)
+		}
+	}
+
+	addresses := map[bool][]string{}
+	for _, address := range splitIniList(ifaceSection.Key("Address").ValueWithShadows()) {
+		ip, _, err := parseCIDR(address)
+		if err != nil {
+			return "", fmt.Errorf("client '%s' had invalid address '%s': %w", clientName, address, err)
+		}
+		addresses[ip.To4() != nil] = append(addresses[ip.To4() != nil], address)
+	}
+
+	for _, family := range []struct {
+		section string
+		ipv4    bool
+	}{
+		{"ipv4", true},
+		{"ipv6", false},
+	} {
+		fmt.Fprintf(&buf, "\n[%s]\n", family.section)
+		if len(addresses[family.ipv4]) == 0 {
+			fmt.Fprintf(&buf, "method=disabled\n")
+			continue
+		}
+		fmt.Fprintf(&buf, "method=manual\n")
+		for idx, address := range addresses[family.ipv4] {
+			fmt.Fprintf(&buf, "address%d=%s\n", idx+1, address)
+		}
+		if len(dnsServers[family.ipv4]) > 0 {
+			fmt.Fprintf(&buf, "dns=%s\n", nmList(dnsServers[family.ipv4]))
+			if len(dnsSearch) > 0 {
+				fmt.Fprintf(&buf, "dns-search=%s\n", nmList(dnsSearch))
+			}
+		}
+	}
+
+	return buf.String(), nil
+}
+
+// splitIniList flattens INI values which may each hold a comma-separated list, as wg-quick allows
+func splitIniList(values []string) []string {
+	items := []string{}
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+func nmList(items []string) string {
+	return strings.Join(items, ";") + ";"
+}
+
+// nmConnectionUUID derives a stable name-based (version 5) UUID for a client's connection, so that re-exporting
+// updates the same NetworkManager connection instead of adding a new one
+func nmConnectionUUID(vlanName string, clientPublicKey string) string {
+	sum := sha1.Sum([]byte("wg-vlan:" + vlanName + ":" + clientPublicKey))
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNMConnection(t *testing.T) {
+	for _, tc := range []struct {
+		fixture     string
+		client      string
+		autoconnect bool
+	}{
+		{"basic", "alice", false},
+		{"extra", "alice", true},
+		{"missing_psk", "alice", false},
+	} {
+		t.Run(fmt.Sprintf("%s/%s", tc.fixture, tc.client), func(t *testing.T) {
+			vlan := loadTestVLAN(t, tc.fixture+".yaml")
+
+			connection, err := vlan.NMConnection(tc.client, tc.autoconnect)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertGolden(t, fmt.Sprintf("%s_%s.nmconnection", tc.fixture, tc.client), connection)
+		})
+	}
+}
+
+func TestNMConnectionUUID(t *testing.T) {
+	uuid := nmConnectionUUID("wg-vlan", "ZK3+qu5D9HjC+GRRym7beOaIQDyHzfQnmnhS6o2653o=")
+	if uuid != nmConnectionUUID("wg-vlan", "ZK3+qu5D9HjC+GRRym7beOaIQDyHzfQnmnhS6o2653o=") {
+		t.Error("UUID is not stable")
+	}
+	if uuid == nmConnectionUUID("wg-vlan", "EmzMM8La6hUiAxbQHQe9mBN8r6iI8E4FlgOfaaWdwlM=") {
+		t.Error("UUID does not depend on the client")
+	}
+	if len(uuid) != 36 || uuid[14] != '5' {
+		t.Errorf("not a version 5 UUID: %s", uuid)
+	}
+}
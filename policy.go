@@ -0,0 +1,226 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// POLICY_GROUP_ALL is a reserved group name that matches every client in the VLAN
+const POLICY_GROUP_ALL = "all"
+
+var policyGroupNamePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+
+// PolicyRule allows traffic between two sets of peers. In the VLAN "policy" section, From/To name client groups; in
+// the "allow" section, they name individual peers or CIDRs.
+type PolicyRule struct {
+	From  []string `yaml:"from" json:"from" schema:"required"`
+	To    []string `yaml:"to" json:"to" schema:"required"`
+	Proto string   `yaml:"proto,omitempty" json:"proto,omitempty" schema:"enum=icmp|tcp|udp"`
+	Ports []uint   `yaml:"ports,omitempty" json:"ports,omitempty"`
+}
+
+func (rule PolicyRule) String() string {
+	desc := fmt.Sprintf("%s -> %s", strings.Join(rule.From, ","), strings.Join(rule.To, ","))
+	if rule.Proto != "" {
+		desc += " " + rule.Proto
+	}
+	if len(rule.Ports) > 0 {
+		ports := []string{}
+		for _, port := range rule.Ports {
+			ports = append(ports, fmt.Sprintf("%d", port))
+		}
+		desc += "/" + strings.Join(ports, ",")
+	}
+	return desc
+}
+
+func (rule PolicyRule) Validate() (vWarnings []string, vError error) {
+	vErrors := []error{}
+	if len(rule.From) == 0 {
+		vErrors = append(vErrors, errors.New("no sources"))
+	}
+	if len(rule.To) == 0 {
+		vErrors = append(vErrors, errors.New("no destinations"))
+	}
+
+	switch rule.Proto {
+	case "", "icmp":
+		if len(rule.Ports) > 0 {
+			vErrors = append(vErrors, errors.New("ports require proto tcp or udp"))
+		}
+	case "tcp", "udp":
+	default:
+		vErrors = append(vErrors, fmt.Errorf("unsupported proto: '%s'", rule.Proto))
+	}
+
+	for _, port := range rule.Ports {
+		if port == 0 || port > 65535 {
+			vErrors = append(vErrors, fmt.Errorf("port out of range: %d", port))
+		}
+	}
+
+	if len(vErrors) > 0 {
+		vError = fmt.Errorf("validation failed: %w", errors.Join(vErrors...))
+	}
+	return
+}
+
+func (rule PolicyRule) endpoints() []string {
+	return append(slices.Clone(rule.From), rule.To...)
+}
+
+// Groups returns the addresses of the clients in each policy group, keyed by group name
+func (vlan VLAN) Groups() (map[string][]*net.IPNet, error) {
+	groups := map[string][]*net.IPNet{POLICY_GROUP_ALL: {}}
+	for _, client := range vlan.Clients {
+		address, err := peerAddress(client.Network)
+		if err != nil {
+			return nil, fmt.Errorf("client '%s' had invalid network '%s': %w", client.PeerName, client.Network, err)
+		}
+		groups[POLICY_GROUP_ALL] = append(groups[POLICY_GROUP_ALL], address)
+		for _, group := range client.Groups {
+			groups[group] = append(groups[group], address)
+		}
+	}
+	return groups, nil
+}
+
+// resolveAllowEndpoint resolves an "allow" rule source or destination, which is either a client name or a CIDR
+func (vlan VLAN) resolveAllowEndpoint(endpoint string) (*net.IPNet, error) {
+	for _, client := range vlan.Clients {
+		if client.PeerName == endpoint {
+			address, err := peerAddress(client.Network)
+			if err != nil {
+				return nil, fmt.Errorf("client '%s' had invalid network '%s': %w", client.PeerName, client.Network, err)
+			}
+			return address, nil
+		}
+	}
+	address, err := peerAddress(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("not a client name or CIDR: '%s'", endpoint)
+	}
+	return address, nil
+}
+
+// firewallRule is a policy or allow rule resolved into concrete addresses, ready for rendering by a firewall backend
+type firewallRule struct {
+	Comment      string
+	Sources      []*net.IPNet
+	Destinations []*net.IPNet
+	Proto        string
+	Ports        []uint
+}
+
+// compileFirewall resolves the VLAN's policy rules, followed by its allow rules
+func (vlan VLAN) compileFirewall() ([]firewallRule, error) {
+	groups, err := vlan.Groups()
+	if err != nil {
+		return nil, err
+	}
+
+	resolveGroups := func(names []string) ([]*net.IPNet, error) {
+		addresses := []*net.IPNet{}
+		for _, name := range names {
+			addresses = append(addresses, groups[name]...)
+		}
+		return addresses, nil
+	}
+
+	resolveEndpoints := func(endpoints []string) ([]*net.IPNet, error) {
+		addresses := []*net.IPNet{}
+		for _, endpoint := range endpoints {
+			address, err := vlan.resolveAllowEndpoint(endpoint)
+			if err != nil {
+				return nil, err
+			}
+			addresses = append(addresses, address)
+		}
+		return addresses, nil
+	}
+
+	rules := []firewallRule{}
+	for _, section := range []struct {
+		name    string
+		rules   []PolicyRule
+		resolve func([]string) ([]*net.IPNet, error)
+	}{
+		{"policy", vlan.Policy, resolveGroups},
+		{"allow", vlan.Allow, resolveEndpoints},
+	} {
+		for idx, rule := range section.rules {
+			sources, err := section.resolve(rule.From)
+			if err != nil {
+				return nil, fmt.Errorf("%s[%d]: %w", section.name, idx, err)
+			}
+			destinations, err := section.resolve(rule.To)
+			if err != nil {
+				return nil, fmt.Errorf("%s[%d]: %w", section.name, idx, err)
+			}
+			rules = append(rules, firewallRule{
+				Comment:      fmt.Sprintf("%s: %s", section.name, rule),
+				Sources:      uniqueAddresses(sources),
+				Destinations: uniqueAddresses(destinations),
+				Proto:        rule.Proto,
+				Ports:        rule.Ports,
+			})
+		}
+	}
+	return rules, nil
+}
+
+// fullTunnelAddresses returns the addresses of the clients that route all their traffic through the server
+func (vlan VLAN) fullTunnelAddresses() ([]*net.IPNet, error) {
+	addresses := []*net.IPNet{}
+	for _, client := range vlan.Clients {
+		if !client.FullTunnel {
+			continue
+		}
+		address, err := peerAddress(client.Network)
+		if err != nil {
+			return nil, fmt.Errorf("client '%s' had invalid network '%s': %w", client.PeerName, client.Network, err)
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, nil
+}
+
+func uniqueAddresses(addresses []*net.IPNet) []*net.IPNet {
+	unique := []*net.IPNet{}
+	for _, address := range addresses {
+		if !slices.ContainsFunc(unique, func(a *net.IPNet) bool { return a.String() == address.String() }) {
+			unique = append(unique, address)
+		}
+	}
+	return unique
+}
+
+// layer4Proto maps a rule protocol to its name in the address family of the network, which differs for ICMP over IPv6
+func layer4Proto(proto string, network *net.IPNet) string {
+	if proto == "icmp" && network.IP.To4() == nil {
+		return "ipv6-icmp"
+	}
+	return proto
+}
+
+// peerAddress parses a peer network as the smallest network containing it: bare IPs become /32 host routes, and
+// CIDRs are masked down to their network address
+func peerAddress(address string) (*net.IPNet, error) {
+	_, ipNet, err := parseCIDR(address)
+	if err != nil {
+		return nil, err
+	}
+	return ipNet, nil
+}
+
+// addressString renders a network as a bare IP when it is a single host, or as a CIDR otherwise
+func addressString(address *net.IPNet) string {
+	if ones, bits := address.Mask.Size(); ones == bits {
+		return address.IP.String()
+	}
+	return address.String()
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// PruneOptions selects the clients to prune: those whose expiry has passed, and, if Handshakes are given, those whose
+// latest handshake is older than InactiveFor. Clients that never completed a handshake are not considered inactive,
+// since they may not have been set up yet.
+type PruneOptions struct {
+	Now         time.Time
+	Handshakes  map[string]time.Time
+	InactiveFor time.Duration
+	// Remove deletes pruned clients, instead of disabling them
+	Remove bool
+}
+
+type PruneResult struct {
+	PeerName string
+	// PublicKey is the client's key as of pruning, since removed clients are no longer in the config
+	PublicKey string
+	Reason    string
+	Removed   bool
+}
+
+func (result PruneResult) String() string {
+	action := "disabled"
+	if result.Removed {
+		action = "removed"
+	}
+	return fmt.Sprintf("%s %s: %s", action, result.PeerName, result.Reason)
+}
+
+// Prune disables or removes expired and inactive clients, returning what was done to each. Clients that are already
+// disabled are only pruned again when removing.
+func (vlan *VLAN) Prune(opts PruneOptions) ([]PruneResult, error) {
+	results := []PruneResult{}
+	clients := []*VLANClient{}
+	for _, client := range vlan.Clients {
+		reason, err := pruneReason(client, opts)
+		if err != nil {
+			return nil, err
+		}
+		if reason == "" || (client.Disabled && !opts.Remove) {
+			clients = append(clients, client)
+			continue
+		}
+
+		results = append(results, PruneResult{PeerName: client.PeerName, PublicKey: client.PublicKey, Reason: reason, Removed: opts.Remove})
+		if !opts.Remove {
+			client.Disabled = true
+			clients = append(clients, client)
+		}
+	}
+	vlan.Clients = clients
+	return results, nil
+}
+
+func pruneReason(client *VLANClient, opts PruneOptions) (string, error) {
+	if client.Expired(opts.Now) {
+		return fmt.Sprintf("expired at %s", client.ExpiresAt.Format(time.RFC3339)), nil
+	}
+	if opts.Handshakes == nil {
+		return "", nil
+	}
+
+	publicKey, err := client.EnsurePublicKey()
+	if err != nil {
+		return "", fmt.Errorf("client '%s' public key failed: %w", client.PeerName, err)
+	}
+	handshake, ok := opts.Handshakes[publicKey]
+	if !ok || handshake.IsZero() {
+		return "", nil
+	}
+	if idle := opts.Now.Sub(handshake); idle > opts.InactiveFor {
+		return fmt.Sprintf("last handshake at %s, %s ago", handshake.Format(time.RFC3339), formatAge(idle)), nil
+	}
+	return "", nil
+}
+
+// formatAge describes a duration in days, or precisely if it is shorter than two days
+func formatAge(age time.Duration) string {
+	if age >= 48*time.Hour {
+		return fmt.Sprintf("%d days", age/(24*time.Hour))
+	}
+	return age.Truncate(time.Minute).String()
+}
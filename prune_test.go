@@ -0,0 +1,153 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func loadTestHandshakes(t *testing.T) map[string]time.Time {
+	t.Helper()
+	fp, err := os.Open(filepath.Join("testdata", "metadata.wg-dump"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer fp.Close()
+	handshakes, err := ParseWgDump(fp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return handshakes
+}
+
+func TestPrune(t *testing.T) {
+	// alice's handshake is a day old, bob has expired and never connected, and the printer's handshake is 169 days old
+	now := time.Unix(1714550000, 0).Add(24 * time.Hour)
+
+	for _, tc := range []struct {
+		name       string
+		opts       PruneOptions
+		expected   []string
+		remaining  []string
+		disabled   []string
+		handshakes bool
+	}{
+		{
+			name:      "expired only",
+			opts:      PruneOptions{Now: now},
+			expected:  []string{"disabled bob: expired at 2020-06-30T12:00:00Z"},
+			remaining: []string{"alice", "bob", "printer"},
+			disabled:  []string{"bob"},
+		},
+		{
+			name: "inactive",
+			opts: PruneOptions{Now: now, InactiveFor: 30 * 24 * time.Hour},
+			expected: []string{
+				"disabled bob: expired at 2020-06-30T12:00:00Z",
+				"disabled printer: last handshake at 2023-11-14T22:13:20Z, 169 days ago",
+			},
+			remaining:  []string{"alice", "bob", "printer"},
+			disabled:   []string{"bob", "printer"},
+			handshakes: true,
+		},
+		{
+			name: "remove",
+			opts: PruneOptions{Now: now, InactiveFor: 30 * 24 * time.Hour, Remove: true},
+			expected: []string{
+				"removed bob: expired at 2020-06-30T12:00:00Z",
+				"removed printer: last handshake at 2023-11-14T22:13:20Z, 169 days ago",
+			},
+			remaining:  []string{"alice"},
+			handshakes: true,
+		},
+		{
+			name:       "nothing inactive",
+			opts:       PruneOptions{Now: now, InactiveFor: 365 * 24 * time.Hour},
+			expected:   []string{"disabled bob: expired at 2020-06-30T12:00:00Z"},
+			remaining:  []string{"alice", "bob", "printer"},
+			disabled:   []string{"bob"},
+			handshakes: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			vlan := loadTestVLAN(t, "metadata.yaml")
+			if tc.handshakes {
+				tc.opts.Handshakes = loadTestHandshakes(t)
+			}
+			results, err := vlan.Prune(tc.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			summary := []string{}
+			for _, result := range results {
+				summary = append(summary, result.String())
+			}
+			if strings.Join(summary, "\n") != strings.Join(tc.expected, "\n") {
+				t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(tc.expected, "\n"), strings.Join(summary, "\n"))
+			}
+
+			remaining, disabled := []string{}, []string{}
+			for _, client := range vlan.Clients {
+				remaining = append(remaining, client.PeerName)
+				if client.Disabled {
+					disabled = append(disabled, client.PeerName)
+				}
+			}
+			if strings.Join(remaining, ",") != strings.Join(tc.remaining, ",") {
+				t.Errorf("expected remaining clients %v, got %v", tc.remaining, remaining)
+			}
+			if strings.Join(disabled, ",") != strings.Join(tc.disabled, ",") {
+				t.Errorf("expected disabled clients %v, got %v", tc.disabled, disabled)
+			}
+		})
+	}
+}
+
+func TestPruneAlreadyDisabled(t *testing.T) {
+	vlan := loadTestVLAN(t, "metadata.yaml")
+	vlan.Client("bob").Disabled = true
+
+	results, err := vlan.Prune(PruneOptions{Now: time.Now()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("expected an already disabled client to be left alone, got %v", results)
+	}
+}
+
+func TestServerIniSkipsDisabled(t *testing.T) {
+	vlan := loadTestVLAN(t, "metadata.yaml")
+	vlan.Client("bob").Disabled = true
+
+	iniFile, err := vlan.ServerIni()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out, err := IniString(iniFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(out, "bob") || strings.Contains(out, "10.20.30.3") {
+		t.Errorf("expected disabled client to be left out of the server config, got:\n%s", out)
+	}
+
+	// The disabled client's address stays reserved
+	next, err := vlan.NextAddress()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if next.String() != "10.20.30.5" {
+		t.Errorf("expected next address 10.20.30.5, got %s", next)
+	}
+
+	warnings, _ := vlan.Validate()
+	for _, warning := range warnings {
+		if strings.Contains(warning, "expired") {
+			t.Errorf("expected no expiry warning for a disabled client, got %s", warning)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// VLANChange describes a change to the config for its git history and audit log: the command making it, what it did,
+// such as ["alice", "10.20.30.4"] for client-add, and the peers it affected
+type VLANChange struct {
+	Command string
+	Args    []string
+	Peers   []ChangedPeer
+}
+
+// ChangedPeer is a peer affected by a change, with its public key as of the change
+type ChangedPeer struct {
+	Name      string
+	PublicKey string
+}
+
+func (change VLANChange) String() string {
+	return strings.Join(append([]string{change.Command}, change.Args...), " ")
+}
+
+func changedClient(client *VLANClient) ChangedPeer {
+	return ChangedPeer{Name: client.PeerName, PublicKey: client.PublicKey}
+}
+
+func changedServer(server VLANServer) ChangedPeer {
+	return ChangedPeer{Name: server.PeerName, PublicKey: server.PublicKey}
+}
+
+// allPeers lists the server and every client, for changes that affect them all
+func (vlan VLAN) allPeers() []ChangedPeer {
+	peers := []ChangedPeer{changedServer(vlan.Server)}
+	for _, client := range vlan.Clients {
+		peers = append(peers, changedClient(client))
+	}
+	return peers
+}
+
+// ChangeRecorder records the changes written to a config file
+type ChangeRecorder struct {
+	// GitHistory commits each change into the git repository holding the config
+	GitHistory bool
+	// AuditLog, if set, is the file each change is appended to as audit log entries
+	AuditLog string
+	// Actor names who makes the changes in the audit log
+	Actor string
+}
+
+// Record records a change written to the config file at path. It returns the hash of the commit made for it, or the
+// zero hash if there was none. Warnings about the history go to warningLogger.
+func (recorder ChangeRecorder) Record(path string, change VLANChange, now time.Time, warningLogger *log.Logger) (plumbing.Hash, error) {
+	if recorder.AuditLog != "" {
+		// One log may cover several configs, so they are told apart by their full paths
+		config, err := filepath.Abs(path)
+		if err != nil {
+			config = path
+		}
+		if err := AppendAuditLog(recorder.AuditLog, change.auditEntries(recorder.Actor, config, now)); err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("failed to append to audit log (%s): %w", recorder.AuditLog, err)
+		}
+	}
+	if !recorder.GitHistory {
+		return plumbing.ZeroHash, nil
+	}
+	return CommitConfigChange(path, change.String(), now, warningLogger)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+)
+
+// RenumberResult records the move of one address of the VLAN into the new subnet
+type RenumberResult struct {
+	Name       string
+	OldAddress string
+	NewAddress string
+	// Reallocated is set when the address could not keep its offset within the subnet
+	Reallocated bool
+}
+
+// renumberEntry is an address to move, with a setter for writing the new address back
+type renumberEntry struct {
+	name    string
+	address string
+	set     func(address string)
+}
+
+type renumberExtra struct {
+	name  string
+	extra IniExtra
+}
+
+// Renumber moves the VLAN into a new subnet, given with the server's new address in CIDR notation. Client and invite
+// addresses keep their offset within the subnet where possible, and are moved to the next free address otherwise. CIDRs
+// in allow rules follow the peer with that address, become the new subnet if they were the old one, or else keep their
+// offset. Warnings name the extra overrides that mention addresses in the old subnet, which are not rewritten.
+func (vlan *VLAN) Renumber(network string) ([]RenumberResult, []string, error) {
+	if !strings.Contains(network, "/") {
+		return nil, nil, fmt.Errorf("network must be given in CIDR notation, such as 10.50.0.1/16: '%s'", network)
+	}
+	newServerIP, newNet, err := net.ParseCIDR(network)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid network '%s': %w", network, err)
+	}
+	oldServerIP, oldNet, err := parseCIDR(vlan.Server.Network)
+	if err != nil {
+		return nil, nil, fmt.Errorf("server had invalid network '%s': %w", vlan.Server.Network, err)
+	}
+	if (newServerIP.To4() == nil) != (oldServerIP.To4() == nil) {
+		return nil, nil, errors.New("cannot renumber between IPv4 and IPv6")
+	}
+	if newServerIP.Equal(newNet.IP) {
+		return nil, nil, fmt.Errorf("server address %s is the subnet's network address", newServerIP)
+	}
+
+	results := []RenumberResult{{Name: vlan.Server.PeerName, OldAddress: vlan.Server.Network, NewAddress: network}}
+	takenIPs := []net.IP{newServerIP}
+	takenNets := []net.IPNet{}
+
+	entries := []renumberEntry{}
+	for _, client := range vlan.Clients {
+		client := client
+		entries = append(entries, renumberEntry{client.PeerName, client.Network, func(address string) { client.Network = address }})
+	}
+	for _, invite := range vlan.Invites {
+		invite := invite
+		entries = append(entries, renumberEntry{invite.PeerName + " (invite)", invite.Network, func(address string) { invite.Network = address }})
+	}
+
+	// Addresses that keep their offset are placed first, so that moved ones cannot take their place
+	moved := make([]string, len(entries))
+	for idx, entry := range entries {
+		_, entryNet, err := parseCIDR(entry.address)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s had invalid network '%s': %w", entry.name, entry.address, err)
+		}
+		mapped, ok := renumberNet(entryNet, oldNet, newNet)
+		if !ok || mapped.IP.Equal(newNet.IP) || renumberTaken(mapped, takenIPs, takenNets) {
+			continue
+		}
+		takenNets = append(takenNets, *mapped)
+		moved[idx] = renumberFormat(entry.address, mapped)
+	}
+	// Moved networks by their old network, for allow rules that name a peer by its address
+	movedNets := map[string]*net.IPNet{}
+	_, oldServerNet, _ := parseCIDR(oldServerIP.String())
+	_, newServerNet, _ := parseCIDR(newServerIP.String())
+	movedNets[oldServerNet.String()] = newServerNet
+	for idx, entry := range entries {
+		reallocated := moved[idx] == ""
+		if reallocated {
+			if _, entryNet, _ := parseCIDR(entry.address); ones(entryNet) != len(entryNet.Mask)*8 {
+				return nil, nil, fmt.Errorf("no room for %s's subnet %s in %s", entry.name, entry.address, newNet)
+			}
+			next, err := pickNextIP(*newNet, takenIPs, takenNets)
+			if err != nil {
+				return nil, nil, fmt.Errorf("no address left for %s in %s", entry.name, newNet)
+			}
+			takenIPs = append(takenIPs, *next)
+			moved[idx] = next.String()
+		}
+		entry.set(moved[idx])
+		_, oldEntryNet, _ := parseCIDR(entry.address)
+		_, newEntryNet, _ := parseCIDR(moved[idx])
+		movedNets[oldEntryNet.String()] = newEntryNet
+		results = append(results, RenumberResult{Name: entry.name, OldAddress: entry.address, NewAddress: moved[idx], Reallocated: reallocated})
+	}
+
+	for ruleIdx := range vlan.Allow {
+		for _, endpoints := range [][]string{vlan.Allow[ruleIdx].From, vlan.Allow[ruleIdx].To} {
+			for idx, endpoint := range endpoints {
+				if vlan.Client(endpoint) != nil {
+					continue
+				}
+				_, endpointNet, err := parseCIDR(endpoint)
+				if err != nil || !oldNet.Contains(endpointNet.IP) {
+					continue
+				}
+				// A rule for the whole subnet covers the whole new subnet, rather than keeping its prefix length
+				mapped, ok := movedNets[endpointNet.String()]
+				if endpointNet.String() == oldNet.String() {
+					mapped, ok = newNet, true
+				}
+				if !ok {
+					mapped, ok = renumberNet(endpointNet, oldNet, newNet)
+				}
+				if !ok {
+					return nil, nil, fmt.Errorf("allow[%d]: %s does not fit in %s", ruleIdx, endpoint, newNet)
+				}
+				endpoints[idx] = renumberFormat(endpoint, mapped)
+				results = append(results, RenumberResult{Name: fmt.Sprintf("allow[%d]", ruleIdx), OldAddress: endpoint, NewAddress: endpoints[idx]})
+			}
+		}
+	}
+
+	vlan.Server.Network = network
+
+	warnings := []string{}
+	extras := []renumberExtra{
+		{"server extra", vlan.Server.InterfaceExtra},
+		{"server peer_extra", vlan.Server.PeerExtra},
+	}
+	for _, client := range vlan.Clients {
+		extras = append(extras,
+			renumberExtra{fmt.Sprintf("client %s extra", client.PeerName), client.InterfaceExtra},
+			renumberExtra{fmt.Sprintf("client %s peer_extra", client.PeerName), client.PeerExtra},
+		)
+	}
+	for _, e := range extras {
+		for _, entry := range e.extra {
+			for _, value := range splitIniList(entry.Values) {
+				if ip, _, err := parseCIDR(value); err == nil && oldNet.Contains(ip) {
+					warnings = append(warnings, fmt.Sprintf("%s %s mentions %s in the old subnet; update it by hand", e.name, entry.Key, value))
+				}
+			}
+		}
+	}
+
+	return results, warnings, nil
+}
+
+// renumberNet moves a network to the same offset within another subnet, if it fits there
+func renumberNet(address *net.IPNet, oldNet *net.IPNet, newNet *net.IPNet) (*net.IPNet, bool) {
+	if !oldNet.Contains(address.IP) || ones(address) < ones(newNet) {
+		return nil, false
+	}
+	oldFirst, _ := ipNetEdges(*oldNet)
+	newFirst, newLast := ipNetEdges(*newNet)
+	offset := big.NewInt(0).Sub(big.NewInt(0).SetBytes(address.IP), big.NewInt(0).SetBytes(oldFirst))
+	mapped := big.NewInt(0).Add(big.NewInt(0).SetBytes(newFirst), offset)
+	if mapped.Cmp(big.NewInt(0).SetBytes(newLast)) > 0 {
+		return nil, false
+	}
+
+	ip := make(net.IP, len(address.Mask))
+	mapped.FillBytes(ip)
+	mappedNet := &net.IPNet{IP: ip.Mask(address.Mask), Mask: address.Mask}
+	if !newNet.Contains(mappedNet.IP) {
+		return nil, false
+	}
+	if _, last := ipNetEdges(*mappedNet); !newNet.Contains(last) {
+		return nil, false
+	}
+	return mappedNet, true
+}
+
+// renumberTaken checks whether a network overlaps any taken address
+func renumberTaken(address *net.IPNet, takenIPs []net.IP, takenNets []net.IPNet) bool {
+	for _, ip := range takenIPs {
+		if address.Contains(ip) {
+			return true
+		}
+	}
+	for _, taken := range takenNets {
+		if taken.Contains(address.IP) || address.Contains(taken.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// renumberFormat writes a moved address the way the original was written: as a bare IP, or with a prefix length
+func renumberFormat(original string, address *net.IPNet) string {
+	if strings.Contains(original, "/") {
+		return address.String()
+	}
+	return address.IP.String()
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRenumber(t *testing.T) {
+	vlan := loadTestVLAN(t, "allow.yaml")
+	results, warnings, err := vlan.Renumber("10.50.0.3/16")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []RenumberResult{
+		{Name: "wg-vlan", OldAddress: "10.20.30.1/24", NewAddress: "10.50.0.3/16"},
+		{Name: "alice", OldAddress: "10.20.30.2", NewAddress: "10.50.0.2"},
+		// bob's offset is taken by the server's new address
+		{Name: "bob", OldAddress: "10.20.30.3", NewAddress: "10.50.0.1", Reallocated: true},
+		{Name: "nas", OldAddress: "10.20.30.16/28", NewAddress: "10.50.0.16/28"},
+		{Name: "allow[1]", OldAddress: "10.20.30.0/28", NewAddress: "10.50.0.0/28"},
+		// An allow rule naming bob by address follows him
+		{Name: "allow[2]", OldAddress: "10.20.30.3", NewAddress: "10.50.0.1"},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("expected %+v, got %+v", expected, results)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+	if vlan.Server.Network != "10.50.0.3/16" || vlan.Client("nas").Network != "10.50.0.16/28" {
+		t.Errorf("expected the VLAN to be renumbered")
+	}
+	if _, err := vlan.Validate(); err != nil {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+}
+
+func TestRenumberWholeSubnetRule(t *testing.T) {
+	for _, network := range []string{"10.50.0.1/16", "10.20.30.129/25"} {
+		vlan := loadTestVLAN(t, "allow.yaml")
+		vlan.Allow = append(vlan.Allow, PolicyRule{From: []string{"10.20.30.0/24"}, To: []string{"nas"}, Proto: "icmp"})
+		if _, _, err := vlan.Renumber(network); err != nil {
+			t.Fatalf("%s: unexpected error: %v", network, err)
+		}
+		_, newNet, _ := net.ParseCIDR(network)
+		if from := vlan.Allow[len(vlan.Allow)-1].From[0]; from != newNet.String() {
+			t.Errorf("%s: expected the rule to cover %s, got %s", network, newNet, from)
+		}
+	}
+}
+
+func TestRenumberInvite(t *testing.T) {
+	vlan := loadTestVLAN(t, "basic.yaml")
+	vlan.Invites = []*VLANInvite{{ID: "0", PeerName: "carol", Network: "10.20.30.5"}}
+	results, _, err := vlan.Renumber("192.168.7.1/25")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if last := results[len(results)-1]; last.Name != "carol (invite)" || last.NewAddress != "192.168.7.5" {
+		t.Errorf("expected the invite to move, got %+v", last)
+	}
+}
+
+func TestRenumberWarnsAboutExtras(t *testing.T) {
+	vlan := loadTestVLAN(t, "extra.yaml")
+	_, warnings, err := vlan.Renumber("10.50.0.1/16")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{
+		"server peer_extra AllowedIPs mentions 10.20.30.0/24 in the old subnet; update it by hand",
+		"client alice extra DNS mentions 10.20.30.1 in the old subnet; update it by hand",
+		"client bob peer_extra AllowedIPs mentions 10.20.30.3/32 in the old subnet; update it by hand",
+	}
+	if !reflect.DeepEqual(warnings, expected) {
+		t.Errorf("expected %q, got %q", expected, warnings)
+	}
+}
+
+func TestRenumberErrors(t *testing.T) {
+	for _, tc := range []struct {
+		config    string
+		network   string
+		errorPart string
+	}{
+		{"allow.yaml", "10.50.0.1", "CIDR notation"},
+		{"allow.yaml", "10.50.0.0/16", "network address"},
+		{"allow.yaml", "fd00::1/64", "between IPv4 and IPv6"},
+		{"allow.yaml", "10.50.0.1/29", "no room for nas's subnet"},
+		{"basic.yaml", "10.50.0.1/30", "no address left for phone"},
+	} {
+		vlan := loadTestVLAN(t, tc.config)
+		if _, _, err := vlan.Renumber(tc.network); err == nil || !strings.Contains(err.Error(), tc.errorPart) {
+			t.Errorf("%s: expected error containing '%s', got %v", tc.network, tc.errorPart, err)
+		}
+	}
+}
+
+func TestIPNetEdges(t *testing.T) {
+	for _, tc := range []struct {
+		cidr  string
+		first string
+		last  string
+	}{
+		{"10.20.30.1/24", "10.20.30.0", "10.20.30.255"},
+		{"10.50.0.0/16", "10.50.0.0", "10.50.255.255"},
+		{"10.20.30.16/28", "10.20.30.16", "10.20.30.31"},
+		{"fd00::1/120", "fd00::", "fd00::ff"},
+	} {
+		_, subnet, _ := net.ParseCIDR(tc.cidr)
+		first, last := ipNetEdges(*subnet)
+		if first.String() != tc.first || last.String() != tc.last {
+			t.Errorf("%s: expected %s - %s, got %s - %s", tc.cidr, tc.first, tc.last, first, last)
+		}
+	}
+}
+
+func TestPickNextIPSkipsTakenSubnet(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("10.50.0.0/16")
+	_, taken, _ := net.ParseCIDR("10.50.0.0/28")
+	next, err := pickNextIP(*subnet, []net.IP{net.ParseIP("10.50.0.16")}, []net.IPNet{*taken})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The search resumes after the taken subnet, not after the whole VLAN
+	if next.String() != "10.50.0.17" {
+		t.Errorf("expected 10.50.0.17, got %s", next)
+	}
+}
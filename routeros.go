@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// ServerRouterOS renders the server's config as a MikroTik RouterOS script. wanGateway is the upstream gateway that
+// keeps endpoints reachable when a peer's allowed IPs include a default route; it may be empty otherwise.
+func (vlan VLAN) ServerRouterOS(wanGateway string) (string, error) {
+	iniFile, err := vlan.ServerIni()
+	if err != nil {
+		return "", err
+	}
+	iface := vlan.Server.Interface
+	if iface == "" {
+		iface = DEFAULT_INTERFACE
+	}
+	return routerOSScript(iniFile, iface, wanGateway)
+}
+
+// ClientRouterOS renders a client's config as a MikroTik RouterOS script, with wanGateway as for ServerRouterOS
+func (vlan VLAN) ClientRouterOS(clientName string, wanGateway string) (string, error) {
+	iniFile, err := vlan.ClientIni(clientName)
+	if err != nil {
+		return "", err
+	}
+	iface := vlan.Client(clientName).Interface
+	if iface == "" {
+		iface = DEFAULT_INTERFACE
+	}
+	return routerOSScript(iniFile, iface, wanGateway)
+}
+
+// routerOSScript translates a Wireguard INI into RouterOS commands. Unlike wg-quick, RouterOS does not route allowed
+// IPs through the interface by itself, so routes are added for those outside the interface's own networks.
+//
+// A default route is split into two halves that take precedence over the router's own default route, as wg-quick
+// does. Since the halves would also capture the tunnel's traffic to the peer's endpoint, the endpoint gets a route of
+// its own through wanGateway.
+func routerOSScript(iniFile *ini.File, iface string, wanGateway string) (string, error) {
+	ifaceSection := iniFile.Section("Interface")
+
+	buf := strings.Builder{}
+	comment := iniComment(ifaceSection)
+	if comment != "" {
+		fmt.Fprintf(&buf, "# %s\n", comment)
+	}
+
+	ifaceArgs := []string{
+		"name=" + routerOSQuote(iface),
+		"private-key=" + routerOSQuote(ifaceSection.Key("PrivateKey").String()),
+	}
+	for _, key := range []struct{ ini, routerOS string }{
+		{"ListenPort", "listen-port"},
+		{"MTU", "mtu"},
+	} {
+		if ifaceSection.HasKey(key.ini) {
+			ifaceArgs = append(ifaceArgs, key.routerOS+"="+routerOSQuote(ifaceSection.Key(key.ini).String()))
+		}
+	}
+	if comment != "" {
+		ifaceArgs = append(ifaceArgs, "comment="+routerOSQuote(comment))
+	}
+	fmt.Fprintf(&buf, "/interface wireguard add %s\n", strings.Join(ifaceArgs, " "))
+
+	ownNetworks := []*net.IPNet{}
+	for _, address := range splitIniList(ifaceSection.Key("Address").ValueWithShadows()) {
+		ip, ipNet, err := parseCIDR(address)
+		if err != nil {
+			return "", fmt.Errorf("invalid address '%s': %w", address, err)
+		}
+		ownNetworks = append(ownNetworks, ipNet)
+		command := "/ip address add"
+		if ip.To4() == nil {
+			command = "/ipv6 address add"
+		}
+		fmt.Fprintf(&buf, "%s address=%s interface=%s\n", command, routerOSQuote(address), routerOSQuote(iface))
+	}
+
+	routes := []string{}
+	for _, peerSection := range iniFile.Sections() {
+		if peerSection.Name() != "Peer" {
+			continue
+		}
+
+		peerArgs := []string{
+			"interface=" + routerOSQuote(iface),
+			"public-key=" + routerOSQuote(peerSection.Key("PublicKey").String()),
+		}
+		if peerSection.HasKey("PresharedKey") {
+			peerArgs = append(peerArgs, "preshared-key="+routerOSQuote(peerSection.Key("PresharedKey").String()))
+		}
+		endpointHost := ""
+		if peerSection.HasKey("Endpoint") {
+			endpoint := peerSection.Key("Endpoint").String()
+			host, port, err := net.SplitHostPort(endpoint)
+			if err != nil {
+				return "", fmt.Errorf("invalid endpoint '%s': %w", endpoint, err)
+			}
+			endpointHost = host
+			peerArgs = append(peerArgs, "endpoint-address="+routerOSQuote(host), "endpoint-port="+routerOSQuote(port))
+		}
+		allowedIPs := splitIniList(peerSection.Key("AllowedIPs").ValueWithShadows())
+		peerArgs = append(peerArgs, "allowed-address="+routerOSQuote(strings.Join(allowedIPs, ",")))
+		if peerSection.HasKey("PersistentKeepalive") {
+			peerArgs = append(peerArgs, "persistent-keepalive="+routerOSQuote(peerSection.Key("PersistentKeepalive").String()+"s"))
+		}
+		if peerComment := iniComment(peerSection); peerComment != "" {
+			peerArgs = append(peerArgs, "comment="+routerOSQuote(peerComment))
+		}
+		fmt.Fprintf(&buf, "/interface wireguard peers add %s\n", strings.Join(peerArgs, " "))
+
+		fullTunnel := false
+		for _, allowedIP := range allowedIPs {
+			ip, ipNet, err := parseCIDR(allowedIP)
+			if err != nil {
+				return "", fmt.Errorf("invalid allowed IP '%s': %w", allowedIP, err)
+			}
+			if ones(ipNet) == 0 {
+				fullTunnel = true
+				routes = append(routes, routerOSDefaultRouteHalves(ip)...)
+				continue
+			}
+			covered := false
+			for _, ownNetwork := range ownNetworks {
+				if ownNetwork.Contains(ip) && ones(ownNetwork) <= ones(ipNet) {
+					covered = true
+				}
+			}
+			if !covered {
+				routes = append(routes, ipNet.String())
+			}
+		}
+
+		if fullTunnel && endpointHost != "" {
+			if wanGateway == "" {
+				return "", fmt.Errorf("peer '%s' routes all traffic through the tunnel, which needs a route to its endpoint %s outside it; set the WAN gateway (--wan-gateway) or add the routes by hand", iniComment(peerSection), endpointHost)
+			}
+			fmt.Fprintln(&buf, routerOSEndpointRoute(endpointHost, wanGateway))
+		}
+	}
+
+	for _, route := range routes {
+		ip, _, _ := net.ParseCIDR(route)
+		command := "/ip route add"
+		if ip.To4() == nil {
+			command = "/ipv6 route add"
+		}
+		fmt.Fprintf(&buf, "%s dst-address=%s gateway=%s\n", command, routerOSQuote(route), routerOSQuote(iface))
+	}
+
+	return buf.String(), nil
+}
+
+// routerOSDefaultRouteHalves splits the default route of ip's family into two routes that are more specific than it
+func routerOSDefaultRouteHalves(ip net.IP) []string {
+	if ip.To4() == nil {
+		return []string{"::/1", "8000::/1"}
+	}
+	return []string{"0.0.0.0/1", "128.0.0.0/1"}
+}
+
+// routerOSEndpointRoute routes an endpoint through the WAN gateway. A host name is resolved when the script runs.
+func routerOSEndpointRoute(host string, wanGateway string) string {
+	command := "/ip route add"
+	dstAddress := fmt.Sprintf("[:resolve %s]", routerOSQuote(host))
+	if ip := net.ParseIP(host); ip != nil && ip.To4() != nil {
+		dstAddress = routerOSQuote(ip.String() + "/32")
+	} else if ip != nil {
+		command = "/ipv6 route add"
+		dstAddress = routerOSQuote(ip.String() + "/128")
+	}
+	return fmt.Sprintf("%s dst-address=%s gateway=%s comment=%s", command, dstAddress, routerOSQuote(wanGateway), routerOSQuote("wg-vlan endpoint "+host))
+}
+
+// iniComment returns the first line of a section's comment, which names its peer, without its comment markers
+func iniComment(section *ini.Section) string {
+	firstLine, _, _ := strings.Cut(section.Comment, "\n")
+	return strings.TrimSpace(strings.TrimLeft(firstLine, "#;"))
+}
+
+func ones(ipNet *net.IPNet) int {
+	size, _ := ipNet.Mask.Size()
+	return size
+}
+
+// routerOSQuote double-quotes a value for RouterOS scripts, escaping the characters that are special inside strings
+func routerOSQuote(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, `?`, `\?`)
+	return `"` + replacer.Replace(value) + `"`
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestServerRouterOS(t *testing.T) {
+	for _, fixture := range []string{"basic", "extra"} {
+		t.Run(fixture, func(t *testing.T) {
+			vlan := loadTestVLAN(t, fixture+".yaml")
+
+			script, err := vlan.ServerRouterOS("")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertGolden(t, fixture+"_server.rsc", script)
+		})
+	}
+}
+
+func TestClientRouterOS(t *testing.T) {
+	for _, tc := range []struct {
+		fixture string
+		client  string
+	}{
+		{"basic", "alice"},
+		{"extra", "alice"},
+		{"allow", "alice"},
+	} {
+		t.Run(fmt.Sprintf("%s/%s", tc.fixture, tc.client), func(t *testing.T) {
+			vlan := loadTestVLAN(t, tc.fixture+".yaml")
+
+			script, err := vlan.ClientRouterOS(tc.client, "192.168.88.1")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertGolden(t, fmt.Sprintf("%s_%s.rsc", tc.fixture, tc.client), script)
+		})
+	}
+}
+
+func TestClientRouterOSFullTunnel(t *testing.T) {
+	vlan := loadTestVLAN(t, "allow.yaml")
+
+	// Without a WAN gateway, the endpoint would only be reachable through the tunnel itself
+	if _, err := vlan.ClientRouterOS("alice", ""); err == nil || !strings.Contains(err.Error(), "--wan-gateway") {
+		t.Errorf("expected an error asking for the WAN gateway, got %v", err)
+	}
+
+	vlan.PublicEndpoint = "203.0.113.1:51820"
+	script, err := vlan.ClientRouterOS("alice", "ether1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, line := range []string{
+		`/ip route add dst-address="203.0.113.1/32" gateway="ether1" comment="wg-vlan endpoint 203.0.113.1"`,
+		`/ip route add dst-address="0.0.0.0/1" gateway="wg0"`,
+		`/ip route add dst-address="128.0.0.0/1" gateway="wg0"`,
+	} {
+		if !strings.Contains(script, line+"\n") {
+			t.Errorf("expected %s in script:\n%s", line, script)
+		}
+	}
+}
+
+func TestRouterOSQuote(t *testing.T) {
+	if quoted := routerOSQuote(`a "$b" \c?`); quoted != `"a \"\$b\" \\c\?"` {
+		t.Errorf("got %s", quoted)
+	}
+}
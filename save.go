@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// DRY_RUN_FLAG is the global flag that makes mutating commands print a diff of their changes instead of saving them
+const DRY_RUN_FLAG = "dry-run"
+
+// globalFlags returns the app's own context, the outermost one with an app, for reading global flags. Commands such
+// as prune have flags of the same names as global ones.
+func globalFlags(ctx *cli.Context) *cli.Context {
+	lineage := ctx.Lineage()
+	for idx := len(lineage) - 1; idx >= 0; idx-- {
+		if lineage[idx].App != nil {
+			return lineage[idx]
+		}
+	}
+	return ctx
+}
+
+func globalDryRun(ctx *cli.Context) bool {
+	return globalFlags(ctx).Bool(DRY_RUN_FLAG)
+}
+
+// refuseDryRun stops commands that write each change as it is made, such as serve, ui and tui, from starting under
+// the global --dry-run flag, which they could not honour
+func refuseDryRun(ctx *cli.Context) {
+	if globalDryRun(ctx) {
+		getLogger(ctx).Fatalf("error: %s writes changes as they are made and cannot be previewed with --%s", ctx.Command.Name, DRY_RUN_FLAG)
+	}
+}
+
+// globalRecorder records changes as the global --git, --audit-log and --actor flags ask
+func globalRecorder(ctx *cli.Context) ChangeRecorder {
+	flags := globalFlags(ctx)
+	return ChangeRecorder{
+		GitHistory: flags.Bool(GIT_HISTORY_FLAG),
+		AuditLog:   flags.Path(AUDIT_LOG_FLAG),
+		Actor:      auditActor(flags.String(AUDIT_ACTOR_FLAG)),
+	}
+}
+
+// VLANSaver saves changes to a config file the same way for every frontend: CLI commands through saveVLAN, and the
+// API server, web UI and TUI directly, after validating the change
+type VLANSaver struct {
+	// DryRun makes Save render what would change instead of writing it
+	DryRun bool
+	// Recorder records every change written
+	Recorder ChangeRecorder
+	// Logger, if set, is told of the commits made for changes, and warned about the history
+	Logger *log.Logger
+}
+
+// Save writes the VLAN config to path and records the change, returning whether the config was written. Callers hold
+// the config's lock, from reading the config if they can; see lockConfigFile. On a dry run it
+// writes nothing, and returns the diff of what would change instead. A change that was written but could not be
+// recorded returns an error with written set, since the config has changed all the same.
+func (saver VLANSaver) Save(vlan *VLAN, path string, change VLANChange) (written bool, diff string, err error) {
+	if saver.DryRun {
+		diff, err = VLANDiff(vlan, path)
+		return false, diff, err
+	}
+
+	if err := vlan.WriteTo(path); err != nil {
+		return false, "", err
+	}
+	return true, "", saver.record(path, change)
+}
+
+// record records a change written to the config file at path
+func (saver VLANSaver) record(path string, change VLANChange) error {
+	hash, err := saver.Recorder.Record(path, change, time.Now(), saver.Logger)
+	if err != nil {
+		return fmt.Errorf("wrote %s, but failed to record the change: %w", path, err)
+	}
+	if !hash.IsZero() && saver.Logger != nil {
+		saver.Logger.Printf("committed %s: %s", hash.String()[:8], change)
+	}
+	return nil
+}
+
+// globalSaver saves changes as the global --dry-run, --git, --audit-log and --actor flags ask
+func globalSaver(ctx *cli.Context) VLANSaver {
+	return VLANSaver{DryRun: globalDryRun(ctx), Recorder: globalRecorder(ctx), Logger: getLogger(ctx)}
+}
+
+// saveVLAN saves the VLAN config for a CLI command as the global flags ask; see VLANSaver. On a dry run it prints what
+// would change. It returns whether the config was written, so that commands still print their reports on a dry run but
+// do not claim to have saved anything.
+func saveVLAN(ctx *cli.Context, vlan *VLAN, path string, change VLANChange) (bool, error) {
+	saver := globalSaver(ctx)
+	if !saver.DryRun {
+		configLock, err := lockConfigFile(path)
+		if err != nil {
+			return false, err
+		}
+		defer configLock.Close()
+	}
+	written, diff, err := saver.Save(vlan, path, change)
+	if err != nil && written {
+		getLogger(ctx).Fatalf("error: %s", err.Error())
+	}
+	if err != nil {
+		return false, err
+	}
+	if !written {
+		printDryRun(ctx, diff, path)
+	}
+	return written, nil
+}
+
+// recordChange records a change that a CLI command wrote to the config file itself, as the global flags ask
+func recordChange(ctx *cli.Context, path string, change VLANChange) {
+	if err := globalSaver(ctx).record(path, change); err != nil {
+		getLogger(ctx).Fatalf("error: %s", err.Error())
+	}
+}
+
+// printDryRun prints the diff of a dry run's changes to path
+func printDryRun(ctx *cli.Context, diff string, path string) {
+	cLog := getLogger(ctx)
+	if diff == "" {
+		cLog.Printf("[dry run] no changes to %s", path)
+	} else {
+		io.WriteString(ctx.App.Writer, diff)
+		cLog.Printf("[dry run] not writing %s", path)
+	}
+}
+
+// VLANDiff renders what writing the VLAN to path would change, as unified diffs of the YAML config and of the server
+// INI exported from it. A missing file diffs as empty, as when initializing a new config.
+func VLANDiff(vlan *VLAN, path string) (string, error) {
+	beforeYAML, beforeIni := "", ""
+	beforeName := "/dev/null"
+	original, err := os.ReadFile(path)
+	if err == nil {
+		beforeYAML = string(original)
+		beforeName = diffLabel("a/", path)
+
+		before, err := VLANFromFile(path, nil)
+		if err != nil {
+			return "", err
+		}
+		if beforeIni, err = vlanServerIniString(before); err != nil {
+			return "", fmt.Errorf("failed to render current server ini: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to read config file (%s): %w", path, err)
+	}
+
+	afterYAML, err := vlan.YAML()
+	if err != nil {
+		return "", err
+	}
+	afterIni, err := vlanServerIniString(vlan)
+	if err != nil {
+		return "", fmt.Errorf("failed to render new server ini: %w", err)
+	}
+
+	iniName := interfaceFileName(vlan.Server.Interface)
+	beforeIniName := "/dev/null"
+	if beforeIni != "" {
+		beforeIniName = diffLabel("a/", iniName)
+	}
+	return UnifiedDiff(beforeName, diffLabel("b/", path), beforeYAML, string(afterYAML)) +
+		UnifiedDiff(beforeIniName, diffLabel("b/", iniName), beforeIni, afterIni), nil
+}
+
+func vlanServerIniString(vlan *VLAN) (string, error) {
+	iniFile, err := vlan.ServerIni()
+	if err != nil {
+		return "", err
+	}
+	return IniString(iniFile)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const JSON_SCHEMA_DRAFT = "https://json-schema.org/draft/2020-12/schema"
+
+// schemaDescriptions documents schema properties, keyed by Go type name and JSON field name
+var schemaDescriptions = map[string]string{
+	"VLAN":                     "A Wireguard VLAN: one server that every client connects to",
+	"VLAN.version":             "Config format version; configs without it are migrated from version 0",
+	"VLAN.public_endpoint":     "host:port that clients connect to the server at",
+	"VLAN.server":              "The server every client connects to",
+	"VLAN.keep_alive":          "PersistentKeepalive interval in seconds; 0 disables it",
+	"VLAN.clients":             "Peers connecting to the server",
+	"VLAN.policy":              "Rules allowing traffic between client groups; the firewall exports drop all other traffic between clients, even with no rules",
+	"VLAN.allow":               "Rules allowing traffic between individual peers or CIDRs",
+	"VLAN.invites":             "Client names and addresses reserved for invite tokens that have not been redeemed yet",
+	"VLANInvite.id":            "Random identifier of the invite's token",
+	"VLANInvite.peer_name":     "Name the invited client will have",
+	"VLANInvite.network":       "Address reserved for the invited client",
+	"VLANInvite.expires_at":    "Time after which the token can no longer be redeemed",
+	"VLANServer.peer_name":     "Name of the VLAN, used in config comments",
+	"VLANServer.listen_port":   "UDP port the server listens on",
+	"VLANServer.network":       "Server address and VLAN subnet in CIDR form",
+	"VLANServer.private_key":   "Base64 Wireguard private key",
+	"VLANServer.public_key":    "Base64 Wireguard public key; derived from the private key if unset",
+	"VLANServer.masquerade":    "NAT traffic from full tunnel clients out of the server",
+	"VLANServer.interface":     "Wireguard interface name",
+	"VLANServer.hooks":         "Commands run by wg-quick around bringing the interface up or down",
+	"VLANServer.extra":         "Keys to override in the server's [Interface] section",
+	"VLANServer.peer_extra":    "Keys to override in the server's [Peer] section of every client config",
+	"VLANClient.peer_name":     "Unique client name",
+	"VLANClient.network":       "Client address, optionally in CIDR form",
+	"VLANClient.private_key":   "Base64 Wireguard private key; unset when the client keeps its own",
+	"VLANClient.public_key":    "Base64 Wireguard public key; derived from the private key if unset",
+	"VLANClient.preshared_key": "Base64 Wireguard preshared key",
+	"VLANClient.groups":        "Groups the client belongs to, for policy rules",
+	"VLANClient.full_tunnel":   "Route all of the client's traffic through the server",
+	"VLANClient.interface":     "Wireguard interface name",
+	"VLANClient.hooks":         "Commands run by wg-quick around bringing the interface up or down",
+	"VLANClient.owner":         "Person or team responsible for the client",
+	"VLANClient.description":   "Free-form description of the client",
+	"VLANClient.tags":          "Labels for selecting clients in exports",
+	"VLANClient.expires_at":    "Time after which the client is considered expired",
+	"VLANClient.disabled":      "Leave the client out of the server config, keeping its address reserved",
+	"VLANClient.extra":         "Keys to override in the client's [Interface] section",
+	"VLANClient.peer_extra":    "Keys to override in the client's [Peer] section of the server config",
+	"PolicyRule.from":          "Sources of the allowed traffic",
+	"PolicyRule.to":            "Destinations of the allowed traffic",
+	"PolicyRule.proto":         "Protocol to allow; any if unset",
+	"PolicyRule.ports":         "Destination ports to allow; requires tcp or udp",
+	"PeerHooks.pre_up":         "Commands run before bringing the interface up",
+	"PeerHooks.post_up":        "Commands run after bringing the interface up",
+	"PeerHooks.pre_down":       "Commands run before bringing the interface down",
+	"PeerHooks.post_down":      "Commands run after bringing the interface down",
+	"IniExtra":                 "INI keys to override, in order; a list of values repeats the key",
+}
+
+type jsonSchema map[string]interface{}
+
+// JSONSchema generates a JSON Schema for the VLAN config from the VLAN structs. Properties are named after their
+// JSON tags, and marked required or restricted to choices with a `schema` tag.
+func JSONSchema() ([]byte, error) {
+	defs := map[string]jsonSchema{}
+	root := schemaForStruct(reflect.TypeOf(VLAN{}), defs)
+	root["$schema"] = JSON_SCHEMA_DRAFT
+	root["title"] = "wg-vlan config"
+	root["$defs"] = defs
+
+	out, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+func schemaForType(t reflect.Type, defs map[string]jsonSchema) jsonSchema {
+	if t == reflect.TypeOf(IniExtra{}) {
+		name := t.Name()
+		if _, ok := defs[name]; !ok {
+			scalar := jsonSchema{"type": []string{"string", "number", "boolean"}}
+			defs[name] = jsonSchema{
+				"description": schemaDescriptions[name],
+				"type":        "object",
+				"additionalProperties": jsonSchema{
+					"oneOf": []jsonSchema{scalar, {"type": "array", "items": scalar}},
+				},
+			}
+		}
+		return jsonSchema{"$ref": "#/$defs/" + name}
+	}
+
+	if t == reflect.TypeOf(time.Time{}) {
+		// YAML timestamps may also be plain dates
+		return jsonSchema{"type": "string", "anyOf": []jsonSchema{{"format": "date-time"}, {"format": "date"}}}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaForType(t.Elem(), defs)
+	case reflect.String:
+		return jsonSchema{"type": "string"}
+	case reflect.Bool:
+		return jsonSchema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return jsonSchema{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return jsonSchema{"type": "integer", "minimum": 0}
+	case reflect.Slice:
+		return jsonSchema{"type": "array", "items": schemaForType(t.Elem(), defs)}
+	case reflect.Struct:
+		name := t.Name()
+		if _, ok := defs[name]; !ok {
+			// Reserve the name first, in case the struct refers to itself
+			defs[name] = nil
+			defs[name] = schemaForStruct(t, defs)
+		}
+		return jsonSchema{"$ref": "#/$defs/" + name}
+	}
+	panic(fmt.Sprintf("no JSON schema for type %s", t))
+}
+
+func schemaForStruct(t reflect.Type, defs map[string]jsonSchema) jsonSchema {
+	properties := map[string]jsonSchema{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		property := schemaForType(field.Type, defs)
+		if description, ok := schemaDescriptions[t.Name()+"."+name]; ok {
+			if _, isRef := property["$ref"]; isRef {
+				// Keywords next to a $ref are allowed since draft 2019-09
+				property = jsonSchema{"$ref": property["$ref"], "description": description}
+			} else {
+				property["description"] = description
+			}
+		}
+		for _, option := range strings.Split(field.Tag.Get("schema"), ",") {
+			switch {
+			case option == "required":
+				required = append(required, name)
+			case strings.HasPrefix(option, "enum="):
+				property["enum"] = strings.Split(strings.TrimPrefix(option, "enum="), "|")
+			}
+		}
+		properties[name] = property
+	}
+
+	schema := jsonSchema{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if description, ok := schemaDescriptions[t.Name()]; ok {
+		schema["description"] = description
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestJSONSchemaUpToDate(t *testing.T) {
+	schema, err := JSONSchema()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The published schema lives at the repository root, where editors can reference it
+	if *updateGolden {
+		if err := os.WriteFile("vlan.schema.json", schema, 0644); err != nil {
+			t.Fatalf("failed to update schema: %v", err)
+		}
+	}
+	published, err := os.ReadFile("vlan.schema.json")
+	if err != nil {
+		t.Fatalf("failed to read published schema: %v", err)
+	}
+	if string(published) != string(schema) {
+		t.Errorf("vlan.schema.json is out of date; regenerate it with `go test -run TestJSONSchemaUpToDate -update`")
+	}
+}
+
+func TestJSONSchemaCoversFixtures(t *testing.T) {
+	schema, err := JSONSchema()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	decoded := struct {
+		Properties map[string]interface{}            `json:"properties"`
+		Defs       map[string]map[string]interface{} `json:"$defs"`
+	}{}
+	if err := json.Unmarshal(schema, &decoded); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+
+	// Every key used in the test fixtures must be a known property, since the schema disallows additional ones
+	for _, fixture := range []string{"basic.yaml", "policy.yaml", "allow.yaml", "hooks.yaml", "extra.yaml", "hooks_extra.yaml"} {
+		raw, err := os.ReadFile("testdata/" + fixture)
+		if err != nil {
+			t.Fatalf("failed to read fixture: %v", err)
+		}
+		doc := struct {
+			Server  map[string]interface{}   `yaml:"server"`
+			Clients []map[string]interface{} `yaml:"clients"`
+			Rest    map[string]interface{}   `yaml:",inline"`
+		}{}
+		if err := yaml.Unmarshal(raw, &doc); err != nil {
+			t.Fatalf("failed to parse fixture: %v", err)
+		}
+
+		assertKnown := func(def string, properties map[string]interface{}, keys map[string]interface{}) {
+			for key := range keys {
+				if _, ok := properties[key]; !ok {
+					t.Errorf("%s: %s key '%s' is missing from the schema", fixture, def, key)
+				}
+			}
+		}
+		assertKnown("VLAN", decoded.Properties, doc.Rest)
+		assertKnown("VLANServer", decoded.Defs["VLANServer"]["properties"].(map[string]interface{}), doc.Server)
+		for _, client := range doc.Clients {
+			assertKnown("VLANClient", decoded.Defs["VLANClient"]["properties"].(map[string]interface{}), client)
+		}
+	}
+}
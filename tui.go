@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/skip2/go-qrcode"
+)
+
+const TUI_DEFAULT_WIDTH = 80
+const TUI_DEFAULT_HEIGHT = 24
+
+type tuiMode int
+
+const (
+	TUI_MODE_LIST tuiMode = iota
+	TUI_MODE_DETAIL
+	TUI_MODE_ADD
+	TUI_MODE_CONFIRM_DELETE
+)
+
+type tuiKeyKind int
+
+const (
+	TUI_KEY_RUNE tuiKeyKind = iota
+	TUI_KEY_UP
+	TUI_KEY_DOWN
+	TUI_KEY_ENTER
+	TUI_KEY_ESCAPE
+	TUI_KEY_BACKSPACE
+	TUI_KEY_INTERRUPT
+	TUI_KEY_OTHER
+)
+
+type tuiKey struct {
+	Kind tuiKeyKind
+	Rune rune
+}
+
+// tuiModel is the state of the terminal UI. It is kept apart from the terminal itself, so that it can be driven by
+// key presses and rendered to any writer.
+type tuiModel struct {
+	ConfigFile string
+	VLAN       *VLAN
+	Width      int
+	Height     int
+	// Recorder records every change, as the global --git and --audit-log flags ask
+	Recorder ChangeRecorder
+
+	mode     tuiMode
+	selected int
+	// scroll is the first line of the detail view that is shown
+	scroll  int
+	input   string
+	message string
+	quit    bool
+}
+
+// readTUIKey reads one key press from a terminal in raw mode
+func readTUIKey(reader *bufio.Reader) (tuiKey, error) {
+	r, _, err := reader.ReadRune()
+	if err != nil {
+		return tuiKey{}, err
+	}
+	switch r {
+	case '\r', '\n':
+		return tuiKey{Kind: TUI_KEY_ENTER}, nil
+	case 0x7f, '\b':
+		return tuiKey{Kind: TUI_KEY_BACKSPACE}, nil
+	case 0x03, 0x04:
+		return tuiKey{Kind: TUI_KEY_INTERRUPT}, nil
+	case 0x1b:
+		// A lone escape is the escape key; otherwise it starts a sequence, such as "\x1b[A" for the up arrow
+		if reader.Buffered() == 0 {
+			return tuiKey{Kind: TUI_KEY_ESCAPE}, nil
+		}
+		next, _, err := reader.ReadRune()
+		if err != nil {
+			return tuiKey{}, err
+		}
+		if next != '[' && next != 'O' {
+			return tuiKey{Kind: TUI_KEY_ESCAPE}, nil
+		}
+		sequence := ""
+		for reader.Buffered() > 0 {
+			b, err := reader.ReadByte()
+			if err != nil {
+				return tuiKey{}, err
+			}
+			sequence += string(b)
+			if b >= 0x40 && b <= 0x7e {
+				break
+			}
+		}
+		switch sequence {
+		case "A":
+			return tuiKey{Kind: TUI_KEY_UP}, nil
+		case "B":
+			return tuiKey{Kind: TUI_KEY_DOWN}, nil
+		}
+		return tuiKey{Kind: TUI_KEY_OTHER}, nil
+	}
+	if unicode.IsPrint(r) {
+		return tuiKey{Kind: TUI_KEY_RUNE, Rune: r}, nil
+	}
+	return tuiKey{Kind: TUI_KEY_OTHER}, nil
+}
+
+// runTUI redraws the model after every key press read from in, until it quits or in runs out
+func runTUI(in io.Reader, out io.Writer, model *tuiModel, size func() (int, int)) error {
+	reader := bufio.NewReader(in)
+	for !model.quit {
+		if size != nil {
+			model.Width, model.Height = size()
+		}
+		if _, err := io.WriteString(out, model.Render()); err != nil {
+			return err
+		}
+		key, err := readTUIKey(reader)
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		model.HandleKey(key)
+	}
+	return nil
+}
+
+func (m *tuiModel) HandleKey(key tuiKey) {
+	if key.Kind == TUI_KEY_INTERRUPT {
+		m.quit = true
+		return
+	}
+
+	switch m.mode {
+	case TUI_MODE_LIST:
+		m.message = ""
+		switch {
+		case key.Kind == TUI_KEY_UP || key.Rune == 'k':
+			if m.selected > 0 {
+				m.selected--
+			}
+		case key.Kind == TUI_KEY_DOWN || key.Rune == 'j':
+			if m.selected < len(m.VLAN.Clients)-1 {
+				m.selected++
+			}
+		case key.Kind == TUI_KEY_ENTER:
+			if m.selectedClient() != nil {
+				m.mode = TUI_MODE_DETAIL
+				m.scroll = 0
+			}
+		case key.Rune == 'a':
+			m.mode = TUI_MODE_ADD
+			m.input = ""
+		case key.Rune == 'd':
+			if m.selectedClient() != nil {
+				m.mode = TUI_MODE_CONFIRM_DELETE
+			}
+		case key.Rune == 'q' || key.Kind == TUI_KEY_ESCAPE:
+			m.quit = true
+		}
+
+	case TUI_MODE_DETAIL:
+		switch {
+		case key.Kind == TUI_KEY_UP || key.Rune == 'k':
+			if m.scroll > 0 {
+				m.scroll--
+			}
+		case key.Kind == TUI_KEY_DOWN || key.Rune == 'j':
+			m.scroll++
+		case key.Kind == TUI_KEY_ENTER || key.Kind == TUI_KEY_ESCAPE || key.Rune == 'q':
+			m.mode = TUI_MODE_LIST
+		}
+
+	case TUI_MODE_ADD:
+		switch key.Kind {
+		case TUI_KEY_RUNE:
+			m.input += string(key.Rune)
+		case TUI_KEY_BACKSPACE:
+			if runes := []rune(m.input); len(runes) > 0 {
+				m.input = string(runes[:len(runes)-1])
+			}
+		case TUI_KEY_ESCAPE:
+			m.mode = TUI_MODE_LIST
+		case TUI_KEY_ENTER:
+			m.mode = TUI_MODE_LIST
+			m.addClient(strings.TrimSpace(m.input))
+		}
+
+	case TUI_MODE_CONFIRM_DELETE:
+		m.mode = TUI_MODE_LIST
+		if key.Rune == 'y' || key.Rune == 'Y' {
+			m.deleteClient(m.selectedClient().PeerName)
+		} else {
+			m.message = "deletion cancelled"
+		}
+	}
+}
+
+func (m *tuiModel) selectedClient() *VLANClient {
+	if m.selected < 0 || m.selected >= len(m.VLAN.Clients) {
+		return nil
+	}
+	return m.VLAN.Clients[m.selected]
+}
+
+func (m *tuiModel) addClient(name string) {
+	var newClient *VLANClient
+	err := m.update(func(vlan *VLAN) (VLANChange, error) {
+		var err error
+		if newClient, err = vlan.NewClient(name, ""); err != nil {
+			return VLANChange{}, err
+		}
+		return VLANChange{Command: "client-add", Args: []string{newClient.PeerName, newClient.Network}, Peers: []ChangedPeer{changedClient(newClient)}}, nil
+	})
+	if err != nil {
+		m.message = fmt.Sprintf("failed to create client: %s", err.Error())
+		return
+	}
+	m.selected = len(m.VLAN.Clients) - 1
+	m.message = fmt.Sprintf("successfully created client: %s - %s", newClient.PeerName, newClient.Network)
+}
+
+func (m *tuiModel) deleteClient(name string) {
+	err := m.update(func(vlan *VLAN) (VLANChange, error) {
+		client := vlan.Client(name)
+		if err := vlan.RemoveClient(name); err != nil {
+			return VLANChange{}, err
+		}
+		return VLANChange{Command: "client-remove", Args: []string{name}, Peers: []ChangedPeer{changedClient(client)}}, nil
+	})
+	if err != nil {
+		m.message = fmt.Sprintf("failed to delete client: %s", err.Error())
+		return
+	}
+	if m.selected >= len(m.VLAN.Clients) && m.selected > 0 {
+		m.selected--
+	}
+	m.message = fmt.Sprintf("deleted client: %s", name)
+}
+
+// update applies a change to the config file as it is now, and writes it back if it leaves the config valid. The
+// model shows the written config, or keeps showing the old one if the change failed. The change returns its
+// description for the config's history.
+func (m *tuiModel) update(change func(vlan *VLAN) (VLANChange, error)) error {
+	configLock, err := lockConfigFile(m.ConfigFile)
+	if err != nil {
+		return err
+	}
+	defer configLock.Close()
+
+	vlan, err := VLANFromFile(m.ConfigFile, nil)
+	if err != nil {
+		return err
+	}
+	description, err := change(vlan)
+	if err != nil {
+		return err
+	}
+	if _, err := vlan.Validate(); err != nil {
+		return err
+	}
+	// Nothing is logged, since it would be drawn over the screen; a failure to record is shown as the change's error
+	written, _, err := VLANSaver{Recorder: m.Recorder}.Save(vlan, m.ConfigFile, description)
+	if !written {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	m.VLAN = vlan
+	return err
+}
+
+// Render draws the whole screen, for a terminal in raw mode
+func (m *tuiModel) Render() string {
+	width, height := m.Width, m.Height
+	if width <= 0 {
+		width = TUI_DEFAULT_WIDTH
+	}
+	if height <= 0 {
+		height = TUI_DEFAULT_HEIGHT
+	}
+
+	title := fmt.Sprintf("wg-vlan: %s (%s)", m.VLAN.Server.PeerName, m.VLAN.Server.Network)
+	var body []string
+	var help string
+	switch m.mode {
+	case TUI_MODE_DETAIL:
+		body = m.detailLines()
+		// Keep the last page of the detail view on screen when scrolling past it
+		if maxScroll := len(body) - (height - 3); m.scroll > maxScroll {
+			m.scroll = maxScroll
+		}
+		if m.scroll < 0 {
+			m.scroll = 0
+		}
+		body = body[m.scroll:]
+		help = "↑/↓ scroll · enter/esc back"
+	case TUI_MODE_ADD:
+		body = m.listLines(height - 4)
+		body = append(body, "", "New client name: "+m.input+"█")
+		help = "enter create · esc cancel"
+	case TUI_MODE_CONFIRM_DELETE:
+		body = m.listLines(height - 4)
+		body = append(body, "", fmt.Sprintf("Delete client %s? [y/N]", m.selectedClient().PeerName))
+		help = "y delete · any other key cancel"
+	default:
+		body = m.listLines(height - 3)
+		help = "↑/↓ select · enter show config · a add · d delete · q quit"
+	}
+
+	lines := []string{"\x1b[7m" + tuiPad(title, width) + "\x1b[0m"}
+	lines = append(lines, body...)
+	for len(lines) < height-2 {
+		lines = append(lines, "")
+	}
+	if len(lines) > height-2 {
+		lines = lines[:height-2]
+	}
+	lines = append(lines, m.message, "\x1b[7m"+tuiPad(help, width)+"\x1b[0m")
+
+	// Move home and clear the screen, then clear each line's remainder after drawing it
+	return "\x1b[H\x1b[2J" + strings.Join(lines, "\x1b[K\r\n")
+}
+
+// listLines renders the client list, scrolled so that the selected client is within the given number of lines
+func (m *tuiModel) listLines(available int) []string {
+	if len(m.VLAN.Clients) == 0 {
+		return []string{"", "  no clients yet; press a to add one"}
+	}
+
+	nameWidth := len("NAME")
+	for _, client := range m.VLAN.Clients {
+		if len(client.PeerName) > nameWidth {
+			nameWidth = len(client.PeerName)
+		}
+	}
+	lines := []string{fmt.Sprintf("  %-*s  %-18s  %s", nameWidth, "NAME", "ADDRESS", "STATUS")}
+
+	available--
+	first := 0
+	if available > 0 && m.selected >= available {
+		first = m.selected - available + 1
+	}
+	for idx := first; idx < len(m.VLAN.Clients) && idx-first < available; idx++ {
+		client := m.VLAN.Clients[idx]
+		line := fmt.Sprintf("  %-*s  %-18s  %s", nameWidth, client.PeerName, client.Network, uiClientStatus(client))
+		if idx == m.selected {
+			line = "\x1b[1m>" + line[1:] + "\x1b[0m"
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// detailLines renders the selected client's config and, like `export --format qr`, its QR code
+func (m *tuiModel) detailLines() []string {
+	client := m.selectedClient()
+	lines := []string{"", "Client: " + client.PeerName}
+	// Descriptions may span lines, each of which is drawn separately in raw mode
+	if client.Owner != "" {
+		lines = append(lines, strings.Split("Owner: "+client.Owner, "\n")...)
+	}
+	if client.Description != "" {
+		lines = append(lines, strings.Split("Description: "+client.Description, "\n")...)
+	}
+	lines = append(lines, "")
+
+	iniFile, err := m.VLAN.ClientIni(client.PeerName)
+	if err != nil {
+		return append(lines, fmt.Sprintf("error building ini: %s", err.Error()))
+	}
+	iniText, err := IniString(iniFile)
+	if err != nil {
+		return append(lines, fmt.Sprintf("error writing ini: %s", err.Error()))
+	}
+	lines = append(lines, strings.Split(strings.TrimRight(iniText, "\n"), "\n")...)
+
+	qr, err := qrcode.New(iniText, qrcode.Low)
+	if err != nil {
+		return append(lines, "", fmt.Sprintf("error constructing QR: %s", err.Error()))
+	}
+	lines = append(lines, "")
+	return append(lines, strings.Split(strings.TrimRight(qr.ToSmallString(false), "\n"), "\n")...)
+}
+
+func tuiPad(text string, width int) string {
+	if length := len([]rune(text)); length < width {
+		return text + strings.Repeat(" ", width-length)
+	}
+	return string([]rune(text)[:width])
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func newTestTUI(t *testing.T, configName string) *tuiModel {
+	t.Helper()
+	path := copyTestConfig(t, configName)
+	vlan, err := VLANFromFile(path, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return &tuiModel{ConfigFile: path, VLAN: vlan}
+}
+
+func TestReadTUIKey(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader("\x1b[Aa\r\x1b[B\x7f\x03"))
+	for _, expected := range []tuiKey{
+		{Kind: TUI_KEY_UP},
+		{Kind: TUI_KEY_RUNE, Rune: 'a'},
+		{Kind: TUI_KEY_ENTER},
+		{Kind: TUI_KEY_DOWN},
+		{Kind: TUI_KEY_BACKSPACE},
+		{Kind: TUI_KEY_INTERRUPT},
+	} {
+		key, err := readTUIKey(reader)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if key != expected {
+			t.Errorf("expected %+v, got %+v", expected, key)
+		}
+	}
+}
+
+func TestTUIBrowse(t *testing.T) {
+	model := newTestTUI(t, "basic.yaml")
+	out := strings.Builder{}
+	// Select bob, show his config, then leave the detail view and quit
+	if err := runTUI(strings.NewReader("j\rqq"), &out, model, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	screens := strings.Split(out.String(), "\x1b[H\x1b[2J")
+	if len(screens) != 5 {
+		t.Fatalf("expected 4 screens, got %d", len(screens)-1)
+	}
+	if !strings.Contains(screens[2], "\x1b[1m> bob") {
+		t.Errorf("expected bob to be selected, got:\n%s", screens[2])
+	}
+	if !strings.Contains(screens[3], "Client: bob") || !strings.Contains(screens[3], "Address    = 10.20.30.3/32") || !strings.Contains(screens[3], "█") {
+		t.Errorf("expected bob's config and QR code, got:\n%s", screens[3])
+	}
+	if !model.quit {
+		t.Errorf("expected q to quit")
+	}
+}
+
+func TestTUIAddDeleteClient(t *testing.T) {
+	model := newTestTUI(t, "basic.yaml")
+	if err := runTUI(strings.NewReader("acarl\x7fol\r"), &strings.Builder{}, model, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if model.message != "successfully created client: carol - 10.20.30.5" {
+		t.Errorf("unexpected message: %s", model.message)
+	}
+	vlan, err := VLANFromFile(model.ConfigFile, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vlan.Client("carol") == nil {
+		t.Fatalf("expected carol to be written to the config")
+	}
+
+	// Anything but y cancels a deletion
+	model.HandleKey(tuiKey{Kind: TUI_KEY_RUNE, Rune: 'd'})
+	model.HandleKey(tuiKey{Kind: TUI_KEY_ENTER})
+	if model.message != "deletion cancelled" || model.VLAN.Client("carol") == nil {
+		t.Errorf("expected deletion to be cancelled, got %s", model.message)
+	}
+
+	model.HandleKey(tuiKey{Kind: TUI_KEY_RUNE, Rune: 'd'})
+	if screen := model.Render(); !strings.Contains(screen, "Delete client carol? [y/N]") {
+		t.Errorf("expected confirmation prompt, got:\n%s", screen)
+	}
+	model.HandleKey(tuiKey{Kind: TUI_KEY_RUNE, Rune: 'y'})
+	vlan, err = VLANFromFile(model.ConfigFile, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vlan.Client("carol") != nil || model.VLAN.Client("carol") != nil {
+		t.Errorf("expected carol to be deleted")
+	}
+	if model.selected != 2 {
+		t.Errorf("expected selection to move to the last client, got %d", model.selected)
+	}
+}
+
+func TestTUIFailedChange(t *testing.T) {
+	model := newTestTUI(t, "allow.yaml")
+	model.HandleKey(tuiKey{Kind: TUI_KEY_DOWN})
+	model.HandleKey(tuiKey{Kind: TUI_KEY_RUNE, Rune: 'd'})
+	model.HandleKey(tuiKey{Kind: TUI_KEY_RUNE, Rune: 'y'})
+	if !strings.HasPrefix(model.message, "failed to delete client:") || model.VLAN.Client("bob") == nil {
+		t.Errorf("expected deleting a client named by allow rules to fail, got %s", model.message)
+	}
+
+	model.HandleKey(tuiKey{Kind: TUI_KEY_RUNE, Rune: 'a'})
+	model.HandleKey(tuiKey{Kind: TUI_KEY_RUNE, Rune: 'b'})
+	model.HandleKey(tuiKey{Kind: TUI_KEY_RUNE, Rune: 'o'})
+	model.HandleKey(tuiKey{Kind: TUI_KEY_RUNE, Rune: 'b'})
+	model.HandleKey(tuiKey{Kind: TUI_KEY_ENTER})
+	if model.message != "failed to create client: name is already in use: bob" {
+		t.Errorf("unexpected message: %s", model.message)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+var uciInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// uciInterfaceKeys and uciPeerKeys are the INI keys that UCI scripts translate; any others are left out
+var uciInterfaceKeys = []string{"PrivateKey", "Address", "ListenPort", "FwMark", "MTU", "DNS"}
+var uciPeerKeys = []string{"PublicKey", "PresharedKey", "Endpoint", "PersistentKeepalive", "AllowedIPs"}
+
+// UCI renders a client's config as a script of `uci` commands for OpenWrt, defining the Wireguard interface and a peer
+// section for the server. It is translated from the client's INI, so extra overrides carry over where UCI has an
+// option for them. The returned warnings name the keys it has none for, such as hooks, an interface name that was not
+// configured, since the script replaces any existing interface of the same name, and one that UCI had to rename.
+func (vlan VLAN) UCI(clientName string) (string, []string, error) {
+	iniFile, err := vlan.ClientIni(clientName)
+	if err != nil {
+		return "", nil, err
+	}
+	client := vlan.Client(clientName)
+	warnings := []string{}
+
+	iface := client.Interface
+	if iface == "" {
+		iface = DEFAULT_INTERFACE
+		warnings = append(warnings, fmt.Sprintf("client %s sets no interface; the script replaces any existing OpenWrt interface named %s", client.PeerName, iface))
+	}
+	// UCI section names only allow letters, digits and underscores, so OpenWrt knows the interface by another name
+	if uciIface := uciInvalidChars.ReplaceAllString(iface, "_"); uciIface != iface {
+		warnings = append(warnings, fmt.Sprintf("interface %s is not a valid UCI name; the script names it %s, so bring it up with `ifup %s`", iface, uciIface, uciIface))
+		iface = uciIface
+	}
+	ifaceSection := iniFile.Section("Interface")
+	peerSection := iniFile.Section("Peer")
+
+	for _, section := range []struct {
+		section *ini.Section
+		known   []string
+	}{
+		{ifaceSection, uciInterfaceKeys},
+		{peerSection, uciPeerKeys},
+	} {
+		for _, name := range section.section.KeyStrings() {
+			if !slices.Contains(section.known, name) {
+				warnings = append(warnings, fmt.Sprintf("[%s] %s has no UCI equivalent and is left out", section.section.Name(), name))
+			}
+		}
+	}
+
+	ifaceOption := "network." + iface
+	peerOption := fmt.Sprintf("network.%s_%s", iface, uciInvalidChars.ReplaceAllString(vlan.Server.PeerName, "_"))
+
+	buf := strings.Builder{}
+	fmt.Fprintf(&buf, "# VLAN Client: %s\n", client.PeerName)
+	fmt.Fprintf(&buf, "# Replaces %s and %s, including any existing settings of the same names\n", ifaceOption, peerOption)
+	// Deleting first makes the script idempotent, since list options are appended to
+	fmt.Fprintf(&buf, "uci -q delete %s\n", ifaceOption)
+	fmt.Fprintf(&buf, "uci -q delete %s\n", peerOption)
+
+	fmt.Fprintf(&buf, "uci set %s=interface\n", ifaceOption)
+	fmt.Fprintf(&buf, "uci set %s.proto=%s\n", ifaceOption, uciQuote("wireguard"))
+	fmt.Fprintf(&buf, "uci set %s.private_key=%s\n", ifaceOption, uciQuote(ifaceSection.Key("PrivateKey").String()))
+	for _, address := range splitIniList(ifaceSection.Key("Address").ValueWithShadows()) {
+		fmt.Fprintf(&buf, "uci add_list %s.addresses=%s\n", ifaceOption, uciQuote(address))
+	}
+	for _, key := range []struct{ ini, uci string }{
+		{"ListenPort", "listen_port"},
+		{"FwMark", "fwmark"},
+		{"MTU", "mtu"},
+	} {
+		if ifaceSection.HasKey(key.ini) {
+			fmt.Fprintf(&buf, "uci set %s.%s=%s\n", ifaceOption, key.uci, uciQuote(ifaceSection.Key(key.ini).String()))
+		}
+	}
+	for _, dns := range splitIniList(ifaceSection.Key("DNS").ValueWithShadows()) {
+		option := "dns"
+		if net.ParseIP(dns) == nil {
+			// wg-quick's DNS key mixes name servers and search domains
+			option = "dns_search"
+		}
+		fmt.Fprintf(&buf, "uci add_list %s.%s=%s\n", ifaceOption, option, uciQuote(dns))
+	}
+
+	fmt.Fprintf(&buf, "uci set %s=wireguard_%s\n", peerOption, iface)
+	fmt.Fprintf(&buf, "uci set %s.description=%s\n", peerOption, uciQuote(vlan.Server.PeerName))
+	fmt.Fprintf(&buf, "uci set %s.public_key=%s\n", peerOption, uciQuote(peerSection.Key("PublicKey").String()))
+	if peerSection.HasKey("PresharedKey") {
+		fmt.Fprintf(&buf, "uci set %s.preshared_key=%s\n", peerOption, uciQuote(peerSection.Key("PresharedKey").String()))
+	}
+	if peerSection.HasKey("Endpoint") {
+		endpoint := peerSection.Key("Endpoint").String()
+		host, port, err := net.SplitHostPort(endpoint)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid endpoint '%s': %w", endpoint, err)
+		}
+		fmt.Fprintf(&buf, "uci set %s.endpoint_host=%s\n", peerOption, uciQuote(host))
+		fmt.Fprintf(&buf, "uci set %s.endpoint_port=%s\n", peerOption, uciQuote(port))
+	}
+	if peerSection.HasKey("PersistentKeepalive") {
+		fmt.Fprintf(&buf, "uci set %s.persistent_keepalive=%s\n", peerOption, uciQuote(peerSection.Key("PersistentKeepalive").String()))
+	}
+	for _, allowedIP := range splitIniList(peerSection.Key("AllowedIPs").ValueWithShadows()) {
+		fmt.Fprintf(&buf, "uci add_list %s.allowed_ips=%s\n", peerOption, uciQuote(allowedIP))
+	}
+	fmt.Fprintf(&buf, "uci set %s.route_allowed_ips=%s\n", peerOption, uciQuote("1"))
+
+	fmt.Fprintf(&buf, "uci commit network\n")
+
+	return buf.String(), warnings, nil
+}
+
+// uciQuote single-quotes a value for the shell
+func uciQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUCI(t *testing.T) {
+	for _, tc := range []struct {
+		fixture string
+		client  string
+	}{
+		{"basic", "alice"},
+		{"extra", "alice"},
+		{"hooks", "alice"},
+	} {
+		t.Run(fmt.Sprintf("%s/%s", tc.fixture, tc.client), func(t *testing.T) {
+			vlan := loadTestVLAN(t, tc.fixture+".yaml")
+
+			script, _, err := vlan.UCI(tc.client)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertGolden(t, fmt.Sprintf("%s_%s.uci", tc.fixture, tc.client), script)
+		})
+	}
+}
+
+func TestUCIWarnings(t *testing.T) {
+	for _, tc := range []struct {
+		fixture  string
+		expected []string
+	}{
+		{"basic", []string{"client alice sets no interface; the script replaces any existing OpenWrt interface named wg0"}},
+		{"hooks_extra", []string{
+			"interface wg-vlan0 is not a valid UCI name; the script names it wg_vlan0, so bring it up with `ifup wg_vlan0`",
+			"[Interface] PreUp has no UCI equivalent and is left out",
+			"[Interface] PostUp has no UCI equivalent and is left out",
+			"[Interface] PreDown has no UCI equivalent and is left out",
+		}},
+	} {
+		t.Run(tc.fixture, func(t *testing.T) {
+			vlan := loadTestVLAN(t, tc.fixture+".yaml")
+
+			_, warnings, err := vlan.UCI("alice")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(warnings, tc.expected) {
+				t.Errorf("expected warnings %q, got %q", tc.expected, warnings)
+			}
+		})
+	}
+}
+
+func TestUCIInterfaceName(t *testing.T) {
+	vlan := loadTestVLAN(t, "basic.yaml")
+	vlan.Client("alice").Interface = "wg-vlan"
+
+	script, warnings, err := vlan.UCI("alice")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, line := range []string{"uci set network.wg_vlan=interface\n", "uci set network.wg_vlan_wg_vlan=wireguard_wg_vlan\n"} {
+		if !strings.Contains(script, line) {
+			t.Errorf("expected %q in script:\n%s", line, script)
+		}
+	}
+	if strings.Contains(script, "network.wg-vlan") {
+		t.Errorf("expected no invalid UCI names in script:\n%s", script)
+	}
+	expected := []string{"interface wg-vlan is not a valid UCI name; the script names it wg_vlan, so bring it up with `ifup wg_vlan`"}
+	if !reflect.DeepEqual(warnings, expected) {
+		t.Errorf("expected warnings %q, got %q", expected, warnings)
+	}
+}
+
+func TestUCIQuote(t *testing.T) {
+	if quoted := uciQuote("it's"); quoted != `'it'\''s'` {
+		t.Errorf("got %s", quoted)
+	}
+}
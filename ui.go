@@ -0,0 +1,401 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"embed"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+)
+
+const UI_CSRF_COOKIE = "wg_vlan_csrf"
+const UI_CSRF_FIELD = "csrf_token"
+const UI_PASSWORD_ENV = "WG_VLAN_UI_PASSWORD"
+const UI_QR_SIZE = 320
+
+// UI_CONTENT_SECURITY_POLICY only allows the UI's own stylesheet and forms, and the QR codes inlined as data URLs
+const UI_CONTENT_SECURITY_POLICY = "default-src 'none'; style-src 'self'; img-src data:; form-action 'self'; frame-ancestors 'none'; base-uri 'none'"
+
+//go:embed ui
+var uiFiles embed.FS
+
+// UIServer serves a web UI over a VLAN config file, for adding clients and getting their QR codes without the command
+// line. Like APIServer, it reads the file afresh for every request and holds a lock while changing it.
+type UIServer struct {
+	ConfigFile string
+	// Password, if set, must be given as the password of HTTP basic authentication; the user name is ignored
+	Password string
+	Logger   *log.Logger
+	// Recorder records every change, as the global --git and --audit-log flags ask
+	Recorder ChangeRecorder
+	// Hosts are the names the UI may be reached by in the Host header, besides localhost and loopback addresses. An IP
+	// address stands for itself, and an unspecified one (0.0.0.0 or ::) for any IP address. Checking the Host header
+	// defeats DNS rebinding, where a page on another site points its own name at the UI to get past same-origin checks.
+	Hosts []string
+
+	mu        sync.RWMutex
+	templates map[string]*template.Template
+	static    http.Handler
+}
+
+type uiPage struct {
+	Title     string
+	VLANName  string
+	CSRFToken string
+	Error     string
+	Clients   []*VLANClient
+	Client    *VLANClient
+	Config    string
+	QRCode    template.URL
+}
+
+func NewUIServer(configFile string, password string, logger *log.Logger) (*UIServer, error) {
+	funcs := template.FuncMap{
+		"join":   strings.Join,
+		"status": uiClientStatus,
+	}
+	templates := map[string]*template.Template{}
+	for _, name := range []string{"index.html", "client.html"} {
+		tmpl, err := template.New(name).Funcs(funcs).ParseFS(uiFiles, "ui/templates/layout.html", "ui/templates/"+name)
+		if err != nil {
+			return nil, fmt.Errorf("failed parsing UI template %s: %w", name, err)
+		}
+		templates[name] = tmpl
+	}
+	static, err := fs.Sub(uiFiles, "ui/static")
+	if err != nil {
+		return nil, err
+	}
+
+	return &UIServer{
+		ConfigFile: configFile,
+		Password:   password,
+		Logger:     logger,
+		templates:  templates,
+		static:     http.StripPrefix("/static/", http.FileServer(http.FS(static))),
+	}, nil
+}
+
+func (ui *UIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Security-Policy", UI_CONTENT_SECURITY_POLICY)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+
+	if !ui.hostAllowed(r.Host) {
+		http.Error(w, "unexpected Host header", http.StatusForbidden)
+		return
+	}
+
+	if ui.Password != "" {
+		_, password, ok := r.BasicAuth()
+		if !ok || subtle.ConstantTimeCompare([]byte(password), []byte(ui.Password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="wg-vlan", charset="UTF-8"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/static/") {
+		ui.static.ServeHTTP(w, r)
+		return
+	}
+
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if r.Method == http.MethodPost {
+		if err := ui.checkCSRF(r); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	switch {
+	case r.URL.Path == "/":
+		if uiAllowMethods(w, r, http.MethodGet) {
+			ui.index(w, r, http.StatusOK, "")
+		}
+	case len(path) == 1 && path[0] == "clients":
+		if uiAllowMethods(w, r, http.MethodPost) {
+			ui.addClient(w, r)
+		}
+	case len(path) == 2 && path[0] == "clients":
+		if uiAllowMethods(w, r, http.MethodGet) {
+			ui.client(w, r, path[1])
+		}
+	case len(path) == 3 && path[0] == "clients" && (path[2] == "disable" || path[2] == "enable"):
+		if uiAllowMethods(w, r, http.MethodPost) {
+			ui.setDisabled(w, r, path[1], path[2] == "disable")
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// hostAllowed checks the host of a Host header, with or without a port, against localhost and the UI's Hosts
+func (ui *UIServer) hostAllowed(hostHeader string) bool {
+	host := hostHeader
+	if splitHost, _, err := net.SplitHostPort(hostHeader); err == nil {
+		host = splitHost
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip != nil && ip.IsLoopback() {
+		return true
+	}
+	for _, allowed := range ui.Hosts {
+		if strings.EqualFold(host, allowed) {
+			return true
+		}
+		if allowedIP := net.ParseIP(allowed); ip != nil && allowedIP != nil && (allowedIP.IsUnspecified() || allowedIP.Equal(ip)) {
+			return true
+		}
+	}
+	return false
+}
+
+// newUIPassword generates a password for a UI started without one
+func newUIPassword() (string, error) {
+	password := make([]byte, 18)
+	if _, err := rand.Read(password); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(password), nil
+}
+
+func uiAllowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+// csrfToken returns the browser's CSRF token, setting a new one if it has none. Forms carry the token back, and a
+// cross-site request cannot read the cookie to forge the field.
+func (ui *UIServer) csrfToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	if cookie, err := r.Cookie(UI_CSRF_COOKIE); err == nil && len(cookie.Value) == 64 {
+		return cookie.Value, nil
+	}
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	cookie := &http.Cookie{
+		Name:     UI_CSRF_COOKIE,
+		Value:    hex.EncodeToString(token),
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+		Secure:   r.TLS != nil,
+	}
+	http.SetCookie(w, cookie)
+	return cookie.Value, nil
+}
+
+func (ui *UIServer) checkCSRF(r *http.Request) error {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if originURL, err := url.Parse(origin); err != nil || originURL.Host != r.Host {
+			return errors.New("cross-origin request refused")
+		}
+	}
+	cookie, err := r.Cookie(UI_CSRF_COOKIE)
+	if err != nil || cookie.Value == "" {
+		return errors.New("missing CSRF cookie; reload the page and try again")
+	}
+	if subtle.ConstantTimeCompare([]byte(r.PostFormValue(UI_CSRF_FIELD)), []byte(cookie.Value)) != 1 {
+		return errors.New("invalid CSRF token; reload the page and try again")
+	}
+	return nil
+}
+
+func (ui *UIServer) index(w http.ResponseWriter, r *http.Request, status int, formError string) {
+	ui.mu.RLock()
+	vlan, err := VLANFromFile(ui.ConfigFile, ui.Logger)
+	ui.mu.RUnlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ui.render(w, r, status, "index.html", uiPage{
+		Title:    "Peers",
+		VLANName: vlan.Server.PeerName,
+		Error:    formError,
+		Clients:  vlan.Clients,
+	})
+}
+
+func (ui *UIServer) client(w http.ResponseWriter, r *http.Request, name string) {
+	ui.mu.RLock()
+	vlan, err := VLANFromFile(ui.ConfigFile, ui.Logger)
+	ui.mu.RUnlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	client := vlan.Client(name)
+	if client == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	iniFile, err := vlan.ClientIni(name)
+	if err != nil {
+		// Clients that keep their own private key have no config to show
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	iniText, err := IniString(iniFile)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	png, err := qrcode.Encode(iniText, qrcode.Low, UI_QR_SIZE)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error constructing QR: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	ui.render(w, r, http.StatusOK, "client.html", uiPage{
+		Title:    client.PeerName,
+		VLANName: vlan.Server.PeerName,
+		Client:   client,
+		Config:   iniText,
+		QRCode:   template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png)),
+	})
+}
+
+func (ui *UIServer) addClient(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSpace(r.PostFormValue("peer_name"))
+
+	err := ui.update(func(vlan *VLAN) (VLANChange, error) {
+		client, err := vlan.NewClient(name, "")
+		if err != nil {
+			return VLANChange{}, err
+		}
+		client.Owner = strings.TrimSpace(r.PostFormValue("owner"))
+		client.Description = strings.TrimSpace(r.PostFormValue("description"))
+		return VLANChange{Command: "client-add", Args: []string{client.PeerName, client.Network}, Peers: []ChangedPeer{changedClient(client)}}, nil
+	})
+	if err != nil {
+		ui.index(w, r, http.StatusBadRequest, fmt.Sprintf("failed to create client: %s", err.Error()))
+		return
+	}
+	ui.logf("added client: %s", name)
+
+	http.Redirect(w, r, "/clients/"+url.PathEscape(name), http.StatusSeeOther)
+}
+
+func (ui *UIServer) setDisabled(w http.ResponseWriter, r *http.Request, name string, disabled bool) {
+	err := ui.update(func(vlan *VLAN) (VLANChange, error) {
+		client := vlan.Client(name)
+		if client == nil {
+			return VLANChange{}, fmt.Errorf("no such client: %s", name)
+		}
+		client.Disabled = disabled
+		command := "client-enable"
+		if disabled {
+			command = "client-disable"
+		}
+		return VLANChange{Command: command, Args: []string{name}, Peers: []ChangedPeer{changedClient(client)}}, nil
+	})
+	if err != nil {
+		ui.index(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if disabled {
+		ui.logf("disabled client: %s", name)
+	} else {
+		ui.logf("enabled client: %s", name)
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// update applies a change to the VLAN config and writes it back, if it leaves the config valid. The change returns
+// its description for the config's history.
+func (ui *UIServer) update(change func(vlan *VLAN) (VLANChange, error)) error {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	configLock, err := lockConfigFile(ui.ConfigFile)
+	if err != nil {
+		return err
+	}
+	defer configLock.Close()
+
+	vlan, err := VLANFromFile(ui.ConfigFile, ui.Logger)
+	if err != nil {
+		return err
+	}
+	description, err := change(vlan)
+	if err != nil {
+		return err
+	}
+	if _, err := vlan.Validate(); err != nil {
+		return err
+	}
+	written, _, err := VLANSaver{Recorder: ui.Recorder, Logger: ui.Logger}.Save(vlan, ui.ConfigFile, description)
+	if !written {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err != nil {
+		// The change has been made, so it is reported as done
+		ui.logf("error: %s", err.Error())
+	}
+	return nil
+}
+
+func (ui *UIServer) render(w http.ResponseWriter, r *http.Request, status int, name string, page uiPage) {
+	csrfToken, err := ui.csrfToken(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	page.CSRFToken = csrfToken
+
+	// Render fully before writing, so that a template error does not leave a half-written page
+	buf := bytes.Buffer{}
+	if err := ui.templates[name].ExecuteTemplate(&buf, name, page); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+func (ui *UIServer) logf(format string, args ...interface{}) {
+	if ui.Logger != nil {
+		ui.Logger.Printf(format, args...)
+	}
+}
+
+func uiClientStatus(client *VLANClient) string {
+	switch {
+	case client.Disabled:
+		return "disabled"
+	case client.Expired(time.Now()):
+		return "expired"
+	default:
+		return "active"
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var uiCSRFFieldPattern = regexp.MustCompile(`name="csrf_token" value="([0-9a-f]+)"`)
+
+func newTestUI(t *testing.T, configName string) (*httptest.Server, *http.Client, string) {
+	t.Helper()
+	path := copyTestConfig(t, configName)
+	ui, err := NewUIServer(path, "", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	server := httptest.NewServer(ui)
+	t.Cleanup(server.Close)
+
+	jar, _ := cookiejar.New(nil)
+	client := server.Client()
+	client.Jar = jar
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	return server, client, path
+}
+
+func uiGet(t *testing.T, client *http.Client, target string) (*http.Response, string) {
+	t.Helper()
+	resp, err := client.Get(target)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	buf := bytes.Buffer{}
+	buf.ReadFrom(resp.Body)
+	return resp, buf.String()
+}
+
+func uiPost(t *testing.T, client *http.Client, target string, form url.Values) (*http.Response, string) {
+	t.Helper()
+	resp, err := client.PostForm(target, form)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	buf := bytes.Buffer{}
+	buf.ReadFrom(resp.Body)
+	return resp, buf.String()
+}
+
+func TestUIIndex(t *testing.T) {
+	server, client, _ := newTestUI(t, "metadata.yaml")
+	resp, body := uiGet(t, client, server.URL+"/")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.StatusCode, body)
+	}
+	for _, expected := range []string{`<a href="/clients/alice">alice</a>`, "<td>expired</td>", "<td>printer</td>", `href="/static/style.css"`} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected index to contain %s, got:\n%s", expected, body)
+		}
+	}
+	if !strings.Contains(resp.Header.Get("Content-Security-Policy"), "default-src 'none'") {
+		t.Errorf("expected restrictive content security policy, got %s", resp.Header.Get("Content-Security-Policy"))
+	}
+	if strings.Contains(body, "<script") || strings.Contains(body, "https://") {
+		t.Errorf("expected no scripts or external resources, got:\n%s", body)
+	}
+
+	resp, body = uiGet(t, client, server.URL+"/static/style.css")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, "img.qr") {
+		t.Errorf("expected stylesheet, got %d", resp.StatusCode)
+	}
+}
+
+func TestUIAddClient(t *testing.T) {
+	server, client, path := newTestUI(t, "basic.yaml")
+	_, body := uiGet(t, client, server.URL+"/")
+	match := uiCSRFFieldPattern.FindStringSubmatch(body)
+	if match == nil {
+		t.Fatalf("expected CSRF token in form, got:\n%s", body)
+	}
+
+	resp, body := uiPost(t, client, server.URL+"/clients", url.Values{"peer_name": {"carol"}, "owner": {"carol@example.com"}, "csrf_token": {match[1]}})
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/clients/carol" {
+		t.Fatalf("expected redirect to the new client, got %d %s: %s", resp.StatusCode, resp.Header.Get("Location"), body)
+	}
+	vlan, err := VLANFromFile(path, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if carol := vlan.Client("carol"); carol == nil || carol.Owner != "carol@example.com" {
+		t.Fatalf("expected carol to be written to the config, got %+v", carol)
+	}
+
+	resp, body = uiGet(t, client, server.URL+"/clients/carol")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, `src="data:image/png;base64,`) || !strings.Contains(body, "Address    = 10.20.30.5/32") {
+		t.Errorf("expected QR code and config for carol, got %d:\n%s", resp.StatusCode, body)
+	}
+
+	resp, body = uiPost(t, client, server.URL+"/clients", url.Values{"peer_name": {"carol"}, "csrf_token": {match[1]}})
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(body, "name is already in use: carol") {
+		t.Errorf("expected form error for duplicate name, got %d:\n%s", resp.StatusCode, body)
+	}
+}
+
+func TestUIDisableClient(t *testing.T) {
+	server, client, path := newTestUI(t, "basic.yaml")
+	_, body := uiGet(t, client, server.URL+"/")
+	token := uiCSRFFieldPattern.FindStringSubmatch(body)[1]
+
+	resp, body := uiPost(t, client, server.URL+"/clients/bob/disable", url.Values{"csrf_token": {token}})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected redirect, got %d: %s", resp.StatusCode, body)
+	}
+	vlan, err := VLANFromFile(path, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !vlan.Client("bob").Disabled {
+		t.Errorf("expected bob to be disabled")
+	}
+	_, body = uiGet(t, client, server.URL+"/")
+	if !strings.Contains(body, `action="/clients/bob/enable"`) {
+		t.Errorf("expected enable button for bob, got:\n%s", body)
+	}
+}
+
+func TestUICSRF(t *testing.T) {
+	server, client, path := newTestUI(t, "basic.yaml")
+	_, body := uiGet(t, client, server.URL+"/")
+	token := uiCSRFFieldPattern.FindStringSubmatch(body)[1]
+
+	for _, tc := range []struct {
+		name   string
+		form   url.Values
+		origin string
+	}{
+		{"missing token", url.Values{}, ""},
+		{"wrong token", url.Values{"csrf_token": {strings.Repeat("0", 64)}}, ""},
+		{"cross origin", url.Values{"csrf_token": {token}}, "https://evil.example.com"},
+	} {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/clients/alice/disable", strings.NewReader(tc.form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if tc.origin != "" {
+			req.Header.Set("Origin", tc.origin)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("%s: expected 403, got %d", tc.name, resp.StatusCode)
+		}
+	}
+
+	// Without the cookie, as for a request from another site, the token alone is not enough
+	cookieless := &http.Client{CheckRedirect: client.CheckRedirect}
+	resp, err := cookieless.PostForm(server.URL+"/clients/alice/disable", url.Values{"csrf_token": {token}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 without cookie, got %d", resp.StatusCode)
+	}
+
+	vlan, err := VLANFromFile(path, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vlan.Client("alice").Disabled {
+		t.Errorf("expected refused requests to leave alice enabled")
+	}
+}
+
+func TestUIPassword(t *testing.T) {
+	ui, err := NewUIServer(filepath.Join("testdata", "basic.yaml"), "hunter2", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ui.Hosts = []string{"example.com"}
+	for _, tc := range []struct {
+		password string
+		status   int
+	}{
+		{"", http.StatusUnauthorized},
+		{"wrong", http.StatusUnauthorized},
+		{"hunter2", http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.password != "" {
+			req.SetBasicAuth("admin", tc.password)
+		}
+		rec := httptest.NewRecorder()
+		ui.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("password '%s': expected %d, got %d", tc.password, tc.status, rec.Code)
+		}
+	}
+}
+
+func TestUIHost(t *testing.T) {
+	ui, err := NewUIServer(filepath.Join("testdata", "basic.yaml"), "", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, tc := range []struct {
+		hosts  []string
+		host   string
+		status int
+	}{
+		{nil, "localhost:8081", http.StatusOK},
+		{nil, "127.0.0.1:8081", http.StatusOK},
+		{nil, "[::1]:8081", http.StatusOK},
+		// A name pointed at the UI by DNS rebinding
+		{nil, "rebind.attacker.example:8081", http.StatusForbidden},
+		{[]string{"192.168.1.5"}, "192.168.1.5:8081", http.StatusOK},
+		{[]string{"192.168.1.5"}, "192.168.1.6:8081", http.StatusForbidden},
+		{[]string{"::"}, "192.168.1.6:8081", http.StatusOK},
+		{[]string{"::"}, "rebind.attacker.example:8081", http.StatusForbidden},
+		{[]string{"::", "vpn.example.com"}, "VPN.example.com", http.StatusOK},
+	} {
+		ui.Hosts = tc.hosts
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Host = tc.host
+		rec := httptest.NewRecorder()
+		ui.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("%v, Host %s: expected %d, got %d", tc.hosts, tc.host, tc.status, rec.Code)
+		}
+	}
+}
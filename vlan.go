@@ -6,10 +6,12 @@ import (
 	"log"
 	"net"
 	"os"
+	"slices"
 	"strings"
+	"time"
 
-	"github.com/go-yaml/yaml"
 	"gopkg.in/ini.v1"
+	"gopkg.in/yaml.v3"
 )
 
 const DEFAULT_LISTEN_PORT = 51820
@@ -17,10 +19,17 @@ const DEFAULT_NETWORK = "10.20.30.1/24"
 const DEFAULT_KEEP_ALIVE = 25
 
 type VLAN struct {
-	PublicEndpoint string        `yaml:"public_endpoint"`
-	KeepAlive      uint          `yaml:"keep_alive"`
-	Server         VLANServer    `yaml:"server"`
-	Clients        []*VLANClient `yaml:"clients"`
+	Version        uint          `yaml:"version" json:"version"`
+	PublicEndpoint string        `yaml:"public_endpoint" json:"public_endpoint"`
+	KeepAlive      uint          `yaml:"keep_alive" json:"keep_alive"`
+	Server         VLANServer    `yaml:"server" json:"server" schema:"required"`
+	Clients        []*VLANClient `yaml:"clients" json:"clients"`
+	Policy         []PolicyRule  `yaml:"policy,omitempty" json:"policy,omitempty"`
+	Allow          []PolicyRule  `yaml:"allow,omitempty" json:"allow,omitempty"`
+	Invites        []*VLANInvite `yaml:"invites,omitempty" json:"invites,omitempty"`
+
+	// source locates values in the file the VLAN was read from, if any
+	source *configSource
 }
 
 func (vlan VLAN) NextAddress() (*net.IP, error) {
@@ -39,6 +48,14 @@ func (vlan VLAN) NextAddress() (*net.IP, error) {
 		takenIPs = append(takenIPs, clientIP)
 		takenNets = append(takenNets, *clientNet)
 	}
+	for _, invite := range vlan.Invites {
+		inviteIP, err := ensureIPWithCIDR(invite.Network)
+		if err != nil {
+			return nil, err
+		}
+		ip, _, _ := parseCIDR(inviteIP)
+		takenIPs = append(takenIPs, ip)
+	}
 
 	return pickNextIP(*vlanNetwork, takenIPs, takenNets)
 }
@@ -55,24 +72,97 @@ func (vlan VLAN) Validate() (vWarnings []string, vError error) {
 
 	srvWarnings, srvError := vlan.Server.Validate()
 	if srvError != nil {
-		vErrors = append(vErrors, fmt.Errorf("server: %w", srvError))
+		vErrors = append(vErrors, fmt.Errorf("%s: %w", vlan.at("server", "server"), srvError))
 	}
 	for _, warning := range srvWarnings {
-		vWarnings = append(vWarnings, fmt.Sprintf("server: %s", warning))
+		vWarnings = append(vWarnings, fmt.Sprintf("%s: %s", vlan.at("server", "server"), warning))
 	}
 
 	uniqueClientNames := map[string]struct{}{}
+	// Peers sharing a public key would be indistinguishable to Wireguard
+	publicKeyOwners := map[string]string{}
+	server := vlan.Server
+	if publicKey, err := server.EnsurePublicKey(); err == nil {
+		publicKeyOwners[publicKey] = fmt.Sprintf("server '%s'", server.PeerName)
+	}
 
 	for idx, client := range vlan.Clients {
+		at := vlan.at(fmt.Sprintf("client[%d]", idx), "clients", idx)
 		clWarnings, clError := client.Validate()
 		for _, warning := range clWarnings {
-			vWarnings = append(vWarnings, fmt.Sprintf("client[%d]: %s", idx, warning))
+			vWarnings = append(vWarnings, fmt.Sprintf("%s: %s", at, warning))
 		}
 		if clError != nil {
-			vErrors = append(vErrors, fmt.Errorf("client[%d]: %w", idx, clError))
+			vErrors = append(vErrors, fmt.Errorf("%s: %w", at, clError))
 		}
 		if _, ok := uniqueClientNames[client.PeerName]; ok && client.PeerName != "" {
-			vErrors = append(vErrors, fmt.Errorf("client[%d]: non-unique client name", idx))
+			vErrors = append(vErrors, fmt.Errorf("%s: non-unique client name", at))
+		}
+		uniqueClientNames[client.PeerName] = struct{}{}
+
+		// Deriving a missing public key must not fill it in on the VLAN being validated
+		clientCopy := *client
+		if publicKey, err := clientCopy.EnsurePublicKey(); err == nil {
+			if owner, ok := publicKeyOwners[publicKey]; ok {
+				vErrors = append(vErrors, fmt.Errorf("%s: public key already used by %s", at, owner))
+			} else {
+				publicKeyOwners[publicKey] = fmt.Sprintf("client '%s'", client.PeerName)
+			}
+		}
+	}
+
+	knownGroups := map[string]struct{}{POLICY_GROUP_ALL: {}}
+	for _, client := range vlan.Clients {
+		for _, group := range client.Groups {
+			knownGroups[group] = struct{}{}
+		}
+	}
+
+	for idx, rule := range vlan.Policy {
+		at := vlan.at(fmt.Sprintf("policy[%d]", idx), "policy", idx)
+		ruleWarnings, ruleError := rule.Validate()
+		for _, warning := range ruleWarnings {
+			vWarnings = append(vWarnings, fmt.Sprintf("%s: %s", at, warning))
+		}
+		if ruleError != nil {
+			vErrors = append(vErrors, fmt.Errorf("%s: %w", at, ruleError))
+		}
+		for _, group := range rule.endpoints() {
+			if !policyGroupNamePattern.MatchString(group) {
+				vErrors = append(vErrors, fmt.Errorf("%s: invalid group name: '%s'", at, group))
+			} else if _, ok := knownGroups[group]; !ok {
+				vWarnings = append(vWarnings, fmt.Sprintf("%s: group has no members: %s", at, group))
+			}
+		}
+	}
+
+	for idx, rule := range vlan.Allow {
+		at := vlan.at(fmt.Sprintf("allow[%d]", idx), "allow", idx)
+		ruleWarnings, ruleError := rule.Validate()
+		for _, warning := range ruleWarnings {
+			vWarnings = append(vWarnings, fmt.Sprintf("%s: %s", at, warning))
+		}
+		if ruleError != nil {
+			vErrors = append(vErrors, fmt.Errorf("%s: %w", at, ruleError))
+		}
+		for _, endpoint := range rule.endpoints() {
+			if _, err := vlan.resolveAllowEndpoint(endpoint); err != nil {
+				vErrors = append(vErrors, fmt.Errorf("%s: %w", at, err))
+			}
+		}
+	}
+
+	for idx, invite := range vlan.Invites {
+		at := vlan.at(fmt.Sprintf("invite[%d]", idx), "invites", idx)
+		invWarnings, invError := invite.Validate()
+		for _, warning := range invWarnings {
+			vWarnings = append(vWarnings, fmt.Sprintf("%s: %s", at, warning))
+		}
+		if invError != nil {
+			vErrors = append(vErrors, fmt.Errorf("%s: %w", at, invError))
+		}
+		if vlan.Client(invite.PeerName) != nil {
+			vErrors = append(vErrors, fmt.Errorf("%s: name is already in use by a client: %s", at, invite.PeerName))
 		}
 	}
 
@@ -82,15 +172,17 @@ func (vlan VLAN) Validate() (vWarnings []string, vError error) {
 	return
 }
 
-func (vlan *VLAN) NewClient(name string, privateKeyBase64 string) (*VLANClient, error) {
-	if name == "" {
-		return nil, errors.New("client may not have an empty name")
+// at names a config element in validation findings, along with its position in the source file when known
+func (vlan VLAN) at(name string, path ...interface{}) string {
+	if position := vlan.source.position(path...); position != "" {
+		return fmt.Sprintf("%s (%s)", name, position)
 	}
+	return name
+}
 
-	for _, client := range vlan.Clients {
-		if client.PeerName == name {
-			return nil, fmt.Errorf("name is already in use: %s", name)
-		}
+func (vlan *VLAN) NewClient(name string, privateKeyBase64 string) (*VLANClient, error) {
+	if err := vlan.checkNameFree(name); err != nil {
+		return nil, err
 	}
 
 	clientIP, err := vlan.NextAddress()
@@ -124,14 +216,8 @@ func (vlan *VLAN) NewClient(name string, privateKeyBase64 string) (*VLANClient,
 }
 
 func (vlan *VLAN) NewClientPublic(name string, publicKeyBase64 string) (*VLANClient, error) {
-	if name == "" {
-		return nil, errors.New("client may not have an empty name")
-	}
-
-	for _, client := range vlan.Clients {
-		if client.PeerName == name {
-			return nil, fmt.Errorf("name is already in use: %s", name)
-		}
+	if err := vlan.checkNameFree(name); err != nil {
+		return nil, err
 	}
 
 	clientIP, err := vlan.NextAddress()
@@ -157,12 +243,16 @@ func (vlan *VLAN) NewClientPublic(name string, publicKeyBase64 string) (*VLANCli
 }
 
 type VLANServer struct {
-	PeerName       string            `yaml:"peer_name"`
-	ListenPort     uint              `yaml:"listen_port"`
-	Network        string            `yaml:"network"`
-	PrivateKey     string            `yaml:"private_key"`
-	PublicKey      string            `yaml:"public_key,omitempty"`
-	InterfaceExtra map[string]string `yaml:"extra,omitempty"`
+	PeerName       string    `yaml:"peer_name" json:"peer_name" schema:"required"`
+	ListenPort     uint      `yaml:"listen_port" json:"listen_port" schema:"required"`
+	Network        string    `yaml:"network" json:"network" schema:"required"`
+	PrivateKey     string    `yaml:"private_key" json:"private_key,omitempty" schema:"required"`
+	PublicKey      string    `yaml:"public_key,omitempty" json:"public_key,omitempty"`
+	Masquerade     bool      `yaml:"masquerade,omitempty" json:"masquerade,omitempty"`
+	Interface      string    `yaml:"interface,omitempty" json:"interface,omitempty"`
+	Hooks          PeerHooks `yaml:"hooks,omitempty" json:"hooks,omitempty"`
+	InterfaceExtra IniExtra  `yaml:"extra,omitempty" json:"extra,omitempty"`
+	PeerExtra      IniExtra  `yaml:"peer_extra,omitempty" json:"peer_extra,omitempty"`
 }
 
 func (srv *VLANServer) EnsurePublicKey() (string, error) {
@@ -181,6 +271,12 @@ func (srv VLANServer) Validate() (vWarnings []string, vError error) {
 	vErrors := []error{}
 	if srv.PeerName == "" {
 		vErrors = append(vErrors, errors.New("name not set"))
+	} else if err := checkFileNamePart(srv.PeerName); err != nil {
+		vErrors = append(vErrors, fmt.Errorf("name invalid: %w", err))
+	}
+
+	if err := checkFileNamePart(srv.Interface); err != nil {
+		vErrors = append(vErrors, fmt.Errorf("interface invalid: %w", err))
 	}
 
 	if srv.ListenPort == 0 {
@@ -201,19 +297,51 @@ func (srv VLANServer) Validate() (vWarnings []string, vError error) {
 		}
 	}
 
+	if _, err := srv.Hooks.Validate(); err != nil {
+		vErrors = append(vErrors, fmt.Errorf("hooks invalid: %w", err))
+	}
+	if _, err := srv.InterfaceExtra.Validate(); err != nil {
+		vErrors = append(vErrors, fmt.Errorf("extra invalid: %w", err))
+	}
+	if _, err := srv.PeerExtra.Validate(); err != nil {
+		vErrors = append(vErrors, fmt.Errorf("peer_extra invalid: %w", err))
+	}
+
 	if len(vErrors) > 0 {
 		vError = fmt.Errorf("validation failed: %w", errors.Join(vErrors...))
 	}
 	return
 }
 
+// checkFileNamePart rejects peer and interface names that exports could not safely use in file names, where a path
+// separator or a "." or ".." name would place the file outside the output directory
+func checkFileNamePart(name string) error {
+	if strings.ContainsAny(name, "/\\\x00") {
+		return fmt.Errorf("'%s' contains a path separator or NUL", name)
+	}
+	if name == "." || name == ".." {
+		return fmt.Errorf("'%s' is not a usable file name", name)
+	}
+	return nil
+}
+
 type VLANClient struct {
-	PeerName       string            `yaml:"peer_name"`
-	Network        string            `yaml:"network"`
-	PrivateKey     string            `yaml:"private_key,omitempty"`
-	PublicKey      string            `yaml:"public_key"`
-	PresharedKey   string            `yaml:"preshared_key,omitempty"`
-	InterfaceExtra map[string]string `yaml:"extra,omitempty"`
+	PeerName       string     `yaml:"peer_name" json:"peer_name" schema:"required"`
+	Network        string     `yaml:"network" json:"network" schema:"required"`
+	PrivateKey     string     `yaml:"private_key,omitempty" json:"private_key,omitempty"`
+	PublicKey      string     `yaml:"public_key" json:"public_key"`
+	PresharedKey   string     `yaml:"preshared_key,omitempty" json:"preshared_key,omitempty"`
+	Groups         []string   `yaml:"groups,omitempty" json:"groups,omitempty"`
+	FullTunnel     bool       `yaml:"full_tunnel,omitempty" json:"full_tunnel,omitempty"`
+	Owner          string     `yaml:"owner,omitempty" json:"owner,omitempty"`
+	Description    string     `yaml:"description,omitempty" json:"description,omitempty"`
+	Tags           []string   `yaml:"tags,omitempty" json:"tags,omitempty"`
+	ExpiresAt      *time.Time `yaml:"expires_at,omitempty" json:"expires_at,omitempty"`
+	Disabled       bool       `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+	Interface      string     `yaml:"interface,omitempty" json:"interface,omitempty"`
+	Hooks          PeerHooks  `yaml:"hooks,omitempty" json:"hooks,omitempty"`
+	InterfaceExtra IniExtra   `yaml:"extra,omitempty" json:"extra,omitempty"`
+	PeerExtra      IniExtra   `yaml:"peer_extra,omitempty" json:"peer_extra,omitempty"`
 }
 
 func (cl *VLANClient) EnsurePublicKey() (string, error) {
@@ -255,6 +383,12 @@ func (cl VLANClient) Validate() (vWarnings []string, vError error) {
 	vErrors := []error{}
 	if cl.PeerName == "" {
 		vErrors = append(vErrors, fmt.Errorf("client name unset"))
+	} else if err := checkFileNamePart(cl.PeerName); err != nil {
+		vErrors = append(vErrors, fmt.Errorf("client name invalid: %w", err))
+	}
+
+	if err := checkFileNamePart(cl.Interface); err != nil {
+		vErrors = append(vErrors, fmt.Errorf("client interface invalid: %w", err))
 	}
 
 	if _, _, err := cl.CIDR(); err != nil {
@@ -281,6 +415,34 @@ func (cl VLANClient) Validate() (vWarnings []string, vError error) {
 		vWarnings = append(vWarnings, "client preshared key unset; this is unsafe")
 	}
 
+	for _, tag := range cl.Tags {
+		if strings.TrimSpace(tag) == "" || strings.Contains(tag, ",") {
+			vErrors = append(vErrors, fmt.Errorf("client tag invalid: '%s'", tag))
+		}
+	}
+
+	if cl.Expired(time.Now()) && !cl.Disabled {
+		vWarnings = append(vWarnings, fmt.Sprintf("client expired at %s", cl.ExpiresAt.Format(time.RFC3339)))
+	}
+
+	for _, group := range cl.Groups {
+		if !policyGroupNamePattern.MatchString(group) {
+			vErrors = append(vErrors, fmt.Errorf("client group name invalid: '%s'", group))
+		} else if group == POLICY_GROUP_ALL {
+			vWarnings = append(vWarnings, fmt.Sprintf("client group '%s' is implicit and need not be listed", POLICY_GROUP_ALL))
+		}
+	}
+
+	if _, err := cl.Hooks.Validate(); err != nil {
+		vErrors = append(vErrors, fmt.Errorf("client hooks invalid: %w", err))
+	}
+	if _, err := cl.InterfaceExtra.Validate(); err != nil {
+		vErrors = append(vErrors, fmt.Errorf("client extra invalid: %w", err))
+	}
+	if _, err := cl.PeerExtra.Validate(); err != nil {
+		vErrors = append(vErrors, fmt.Errorf("client peer_extra invalid: %w", err))
+	}
+
 	if len(vErrors) > 0 {
 		vError = fmt.Errorf("validation failed: %w", errors.Join(vErrors...))
 	}
@@ -288,27 +450,40 @@ func (cl VLANClient) Validate() (vWarnings []string, vError error) {
 	return
 }
 
-func (vlan VLAN) WriteTo(path string) error {
-	fp, err := os.Create(path)
-	if err != nil {
-		return err
+// YAML renders the VLAN as it would be written. A VLAN read from a file is rendered by editing that file's document,
+// so that only the changed values are touched and comments survive.
+func (vlan VLAN) YAML() ([]byte, error) {
+	if vlan.source == nil {
+		return marshalYAML(vlan)
 	}
-	enc := yaml.NewEncoder(fp)
-	if err := enc.Encode(vlan); err != nil {
-		return err
+	updated := &yaml.Node{}
+	if err := updated.Encode(vlan); err != nil {
+		return nil, err
 	}
+	mergeYAMLNode(vlan.source.root, updated)
+	return marshalYAML(vlan.source.document)
+}
 
-	if err := enc.Close(); err != nil {
+// WriteTo writes the VLAN as YAML, as rendered by YAML. The file is replaced atomically, so that a failure or a
+// concurrent reader never sees half a config; it keeps the mode of the file it replaces, and a new one is only readable
+// by its owner, since it holds private keys. Callers changing a config that others may change too hold its lock; see
+// lockConfigFile.
+func (vlan VLAN) WriteTo(path string) error {
+	out, err := vlan.YAML()
+	if err != nil {
 		return err
 	}
-	if err := fp.Close(); err != nil {
-		return err
+
+	path = resolveConfigPath(path)
+	var mode os.FileMode = 0600
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
 	}
-	return nil
+	return writeFileAtomic(path, out, mode)
 }
 
 func (vlan VLAN) ServerIni() (*ini.File, error) {
-	iniFile := ini.Empty(ini.LoadOptions{AllowNonUniqueSections: true})
+	iniFile := newWireguardIni()
 
 	iniFile.Section("Interface").Comment = fmt.Sprintf("# VLAN Server: %s", vlan.Server.PeerName)
 
@@ -316,13 +491,31 @@ func (vlan VLAN) ServerIni() (*ini.File, error) {
 	iniFile.Section("Interface").Key("ListenPort").SetValue(fmt.Sprintf("%d", vlan.Server.ListenPort))
 	iniFile.Section("Interface").Key("PrivateKey").SetValue(vlan.Server.PrivateKey)
 
-	for k, v := range vlan.Server.InterfaceExtra {
-		iniFile.Section("Interface").Key(k).SetValue(v)
+	_, vlanNetwork, err := parseCIDR(vlan.Server.Network)
+	if err != nil {
+		return nil, fmt.Errorf("server had invalid network '%s': %w", vlan.Server.Network, err)
+	}
+	err = vlan.Server.Hooks.Render(iniFile.Section("Interface"), HookTemplateData{
+		Interface: interfaceName(vlan.Server.Interface),
+		PeerName:  vlan.Server.PeerName,
+		Address:   vlan.Server.Network,
+		Network:   vlanNetwork.String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("server hooks failed: %w", err)
+	}
+
+	if err := vlan.Server.InterfaceExtra.Apply(iniFile.Section("Interface")); err != nil {
+		return nil, fmt.Errorf("server extra failed: %w", err)
 	}
 
 	for _, client := range vlan.Clients {
+		if client.Disabled {
+			// The client's address stays reserved, since it is still listed in the VLAN
+			continue
+		}
 		sec, _ := iniFile.NewSection("Peer")
-		sec.Comment = fmt.Sprintf("# VLAN Client: %s", client.PeerName)
+		sec.Comment = strings.Join(append([]string{fmt.Sprintf("# VLAN Client: %s", client.PeerName)}, client.metadataComments()...), "\n")
 
 		clientIP, err := ensureIPWithCIDR(client.Network)
 		if err != nil {
@@ -343,19 +536,37 @@ func (vlan VLAN) ServerIni() (*ini.File, error) {
 		if vlan.KeepAlive != 0 {
 			sec.Key("PersistentKeepalive").SetValue(fmt.Sprintf("%d", vlan.KeepAlive))
 		}
+
+		if err := client.PeerExtra.Apply(sec); err != nil {
+			return nil, fmt.Errorf("peer failed '%s': %w", client.PeerName, err)
+		}
 	}
 
 	return iniFile, nil
 }
 
-func (vlan VLAN) ClientIni(clientName string) (*ini.File, error) {
-	var client *VLANClient
-	for _, cl := range vlan.Clients {
-		if cl.PeerName == clientName {
-			client = cl
-			break
+// Client returns the client with the given name, or nil if there is none
+func (vlan VLAN) Client(name string) *VLANClient {
+	for _, client := range vlan.Clients {
+		if client.PeerName == name {
+			return client
 		}
 	}
+	return nil
+}
+
+// RemoveClient deletes a client by name
+func (vlan *VLAN) RemoveClient(name string) error {
+	idx := slices.IndexFunc(vlan.Clients, func(cl *VLANClient) bool { return cl.PeerName == name })
+	if idx < 0 {
+		return fmt.Errorf("no such client: %s", name)
+	}
+	vlan.Clients = slices.Delete(vlan.Clients, idx, idx+1)
+	return nil
+}
+
+func (vlan VLAN) ClientIni(clientName string) (*ini.File, error) {
+	client := vlan.Client(clientName)
 	if client == nil {
 		return nil, fmt.Errorf("no such client: %s", clientName)
 	}
@@ -363,7 +574,7 @@ func (vlan VLAN) ClientIni(clientName string) (*ini.File, error) {
 		return nil, fmt.Errorf("client has no private key defined: %s", clientName)
 	}
 
-	iniFile := ini.Empty(ini.LoadOptions{AllowNonUniqueSections: true})
+	iniFile := newWireguardIni()
 	iniFile.Section("Interface").Comment = fmt.Sprintf("# VLAN Client: %s", client.PeerName)
 	clientIP, err := ensureIPWithCIDR(client.Network)
 	if err != nil {
@@ -372,8 +583,22 @@ func (vlan VLAN) ClientIni(clientName string) (*ini.File, error) {
 	iniFile.Section("Interface").Key("Address").SetValue(clientIP)
 	iniFile.Section("Interface").Key("PrivateKey").SetValue(client.PrivateKey)
 
-	for k, v := range client.InterfaceExtra {
-		iniFile.Section("Interface").Key(k).SetValue(v)
+	_, vlanNetwork, err := parseCIDR(vlan.Server.Network)
+	if err != nil {
+		return nil, fmt.Errorf("server had invalid network '%s': %w", vlan.Server.Network, err)
+	}
+	err = client.Hooks.Render(iniFile.Section("Interface"), HookTemplateData{
+		Interface: interfaceName(client.Interface),
+		PeerName:  client.PeerName,
+		Address:   clientIP,
+		Network:   vlanNetwork.String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("client '%s' hooks failed: %w", clientName, err)
+	}
+
+	if err := client.InterfaceExtra.Apply(iniFile.Section("Interface")); err != nil {
+		return nil, fmt.Errorf("client '%s' extra failed: %w", clientName, err)
 	}
 
 	serverSection, _ := iniFile.NewSection("Peer")
@@ -388,6 +613,9 @@ func (vlan VLAN) ClientIni(clientName string) (*ini.File, error) {
 	if err != nil {
 		return nil, fmt.Errorf("server had invalid network '%s': %w", vlan.Server.Network, err)
 	}
+	if client.FullTunnel {
+		serverIP = defaultRoute(serverIP)
+	}
 	serverSection.Key("AllowedIPs").SetValue(serverIP)
 
 	serverPublicKey, err := vlan.Server.EnsurePublicKey()
@@ -404,22 +632,44 @@ func (vlan VLAN) ClientIni(clientName string) (*ini.File, error) {
 		serverSection.Key("PersistentKeepalive").SetValue(fmt.Sprintf("%d", vlan.KeepAlive))
 	}
 
+	if err := vlan.Server.PeerExtra.Apply(serverSection); err != nil {
+		return nil, fmt.Errorf("server peer extra failed: %w", err)
+	}
+
 	return iniFile, nil
 }
 
+// newWireguardIni creates an empty INI file that allows the repeated sections and keys Wireguard configs use
+func newWireguardIni() *ini.File {
+	return ini.Empty(ini.LoadOptions{
+		// wg-quick does not unquote values, so hook commands containing ";" must not be quoted
+		IgnoreInlineComment:        true,
+		AllowNonUniqueSections:     true,
+		AllowShadows:               true,
+		AllowDuplicateShadowValues: true,
+	})
+}
+
 func VLANFromFile(path string, warningLogger *log.Logger) (*VLAN, error) {
-	fp, err := os.Open(path)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open config file (%s): %w", path, err)
 	}
 
-	vlan := &VLAN{}
-	if err := yaml.NewDecoder(fp).Decode(vlan); err != nil {
-		return nil, fmt.Errorf("failed to decode config file (%s): %w", path, err)
+	document, migrations, err := loadConfigDocument(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate config file (%s): %w", path, err)
+	}
+	if len(migrations) > 0 {
+		if warningLogger != nil {
+			warningLogger.Printf("config is outdated; migrated in memory to version %d; run `wg-vlan migrate` to update the file", CONFIG_VERSION)
+		}
+		data = nil
 	}
 
-	if err := fp.Close(); err != nil {
-		return nil, err
+	vlan, err := decodeConfigDocument(document, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode config file (%s): %w", path, err)
 	}
 
 	vWarnings, vError := vlan.Validate()
@@ -453,3 +703,11 @@ func ensureIPWithCIDR(address string) (string, error) {
 	ipNet.IP = ip
 	return ipNet.String(), nil
 }
+
+// defaultRoute returns the catch-all route of the same address family as the given CIDR
+func defaultRoute(address string) string {
+	if ip, _, err := parseCIDR(address); err == nil && ip.To4() == nil {
+		return "::/0"
+	}
+	return "0.0.0.0/0"
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WG_DUMP_PEER_FIELDS is the number of fields of a peer line in `wg show <interface> dump` output; `wg show all dump`
+// prefixes every line with the interface name
+const WG_DUMP_PEER_FIELDS = 8
+
+// ParseWgDump reads the latest handshake time of each peer from `wg show` dump output, keyed by public key. Peers that
+// never completed a handshake have the zero time.
+func ParseWgDump(r io.Reader) (map[string]time.Time, error) {
+	handshakes := map[string]time.Time{}
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != WG_DUMP_PEER_FIELDS && len(fields) != WG_DUMP_PEER_FIELDS+1 {
+			// Interface lines have fewer fields
+			if len(fields) < WG_DUMP_PEER_FIELDS-2 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: unexpected number of fields: %d", lineNumber, len(fields))
+		}
+		fields = fields[len(fields)-WG_DUMP_PEER_FIELDS:]
+
+		publicKey := fields[0]
+		seconds, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid latest handshake '%s': %w", lineNumber, fields[4], err)
+		}
+		handshake := time.Time{}
+		if seconds > 0 {
+			handshake = time.Unix(seconds, 0).UTC()
+		}
+		handshakes[publicKey] = handshake
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return handshakes, nil
+}
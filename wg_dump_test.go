@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseWgDump(t *testing.T) {
+	fp, err := os.Open(filepath.Join("testdata", "metadata.wg-dump"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer fp.Close()
+
+	handshakes, err := ParseWgDump(fp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]time.Time{
+		"ZK3+qu5D9HjC+GRRym7beOaIQDyHzfQnmnhS6o2653o=": time.Unix(1714550000, 0).UTC(),
+		"EmzMM8La6hUiAxbQHQe9mBN8r6iI8E4FlgOfaaWdwlM=": {},
+		"0RLQO1vcOdSIwJ3AFrwvinHPrAZD+Rv7RdpKioCj5E4=": time.Unix(1700000000, 0).UTC(),
+	}
+	if len(handshakes) != len(expected) {
+		t.Fatalf("expected %d peers, got %v", len(expected), handshakes)
+	}
+	for publicKey, handshake := range expected {
+		if !handshakes[publicKey].Equal(handshake) {
+			t.Errorf("%s: expected %s, got %s", publicKey, handshake, handshakes[publicKey])
+		}
+	}
+}
+
+func TestParseWgDumpAllInterfaces(t *testing.T) {
+	dump := "wg0\tpriv\tpub\t51820\toff\nwg0\tpeer=\t(none)\t(none)\t10.0.0.2/32\t1714550000\t0\t0\toff\n"
+	handshakes, err := ParseWgDump(strings.NewReader(dump))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !handshakes["peer="].Equal(time.Unix(1714550000, 0)) {
+		t.Errorf("unexpected handshakes: %v", handshakes)
+	}
+}
+
+func TestParseWgDumpInvalid(t *testing.T) {
+	for _, dump := range []string{
+		"peer=\t(none)\t(none)\t10.0.0.2/32\tyesterday\t0\t0\toff\n",
+		"peer=\t(none)\t(none)\t10.0.0.2/32\t0\t0\n",
+	} {
+		if _, err := ParseWgDump(strings.NewReader(dump)); err == nil {
+			t.Errorf("expected error parsing %q", dump)
+		}
+	}
+}